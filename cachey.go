@@ -1,88 +1,170 @@
 package cachey
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/codemaestro64/cachey/store"
-	"github.com/codemaestro64/cachey/store/memory"
-	"github.com/codemaestro64/cachey/store/redis"
+	"golang.org/x/sync/singleflight"
 )
 
 // Cache represents a caching mechanism that wraps a store implementation.
 type Cache struct {
-	store store.Store // The underlying store for caching data.
+	base  store.Store         // The store as given, on which optional interfaces are looked up.
+	store store.ContextStore  // The underlying store for caching data.
+	group *singleflight.Group // Collapses concurrent loads of the same key.
+
+	negativeTTL time.Duration // How long ErrNotFound results are cached; zero disables it.
+	prefix      string        // Prepended to every key before it reaches the store.
 }
 
+// ErrNotFound may be returned by a remember function to report that the
+// requested value does not exist. See Cache.WithNegativeTTL.
+var ErrNotFound = errors.New("cachey: value not found")
+
+// negativeValue is stored in place of values whose loader reported ErrNotFound.
+const negativeValue = "\x00cachey:not-found\x00"
+
 // Supported cache store constants.
 const (
-	MemoryStore = "memory" // Name of the memory store.
-	RedisStore  = "redis"  // Name of the redis store
+	MemoryStore    = "memory"    // Name of the memory store.
+	RedisStore     = "redis"     // Name of the redis store
+	FileStore      = "file"      // Name of the file store.
+	MemcachedStore = "memcached" // Name of the memcached store.
+	BoltStore      = "bolt"      // Name of the bolt store.
+	SQLStore       = "sql"       // Name of the sql store.
 
 	ForeverDuration = -1 // Duration to store data indefinitely.
 )
 
 type StoreConstructorFunc func() store.Store
 
-// stores maps store names to their corresponding store constructors.
-var stores = map[string]StoreConstructorFunc{
-	MemoryStore: memory.NewMemoryStore,
-	RedisStore:  redis.NewRedisStore,
+// New initializes a new Cache instance using the specified store name from
+// DefaultRegistry. It returns an error if the store is not registered.
+func New(storeName string, options ...store.Option) (*Cache, error) {
+	return NewWithRegistry(DefaultRegistry, storeName, options...)
 }
 
-// New initializes a new Cache instance using the specified store name.
-// It returns an error if the store is not registered.
-func New(storeName string, options ...store.Option) (*Cache, error) {
-	storeConstructor, ok := stores[storeName]
+// NewWithRegistry is like New but looks the store up in the given registry.
+func NewWithRegistry(registry *Registry, storeName string, options ...store.Option) (*Cache, error) {
+	storeConstructor, ok := registry.Lookup(storeName)
 	if !ok {
 		return nil, fmt.Errorf("cache store `%s` is not registered", storeName)
 	}
 
-	store := storeConstructor()
+	return NewFromStore(storeConstructor(), options...)
+}
+
+// NewFromStore initializes a new Cache instance around an existing store,
+// such as one configured by hand, a decorated store or a fake in tests. The
+// options are applied to the store before it is initialized.
+func NewFromStore(s store.Store, options ...store.Option) (*Cache, error) {
+	if s == nil {
+		return nil, errors.New("cache store must not be nil")
+	}
 
 	// apply options to the store
 	for _, option := range options {
-		err := option(store)
+		err := option(s)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	// initialize store with applied config
-	err := store.Init()
+	err := s.Init()
 	if err != nil {
 		return nil, err
 	}
 
-	return &Cache{store: store}, nil
+	return &Cache{base: s, store: store.WithContext(s), group: &singleflight.Group{}}, nil
+}
+
+// Store returns the underlying store, as created by its constructor or
+// passed to NewFromStore. Use it to reach store-specific methods; writes
+// made through it bypass the cache's key prefix.
+func (c *Cache) Store() store.Store {
+	return c.base
 }
 
-// Registerstore registers a new cache store with the given name and constructor function.
+// RegisterStore registers a new cache store in DefaultRegistry with the given name and constructor function.
 // Returns an error if the store is already registered.
 func RegisterStore(storeName string, constructorFunc StoreConstructorFunc) error {
-	if _, exists := stores[storeName]; exists {
-		return fmt.Errorf("cache store `%s` is already registered", storeName)
-	}
-	stores[storeName] = constructorFunc
-	return nil
+	return DefaultRegistry.Register(storeName, constructorFunc)
+}
+
+// WithNegativeTTL returns a copy of the cache that remembers ErrNotFound
+// results from remember functions for the given duration, so lookups of
+// missing values do not hit the loader on every call. The copy shares the
+// underlying store, but not in-flight Remember calls, whose results depend
+// on the negative TTL.
+func (c *Cache) WithNegativeTTL(ttl time.Duration) *Cache {
+	clone := *c
+	clone.negativeTTL = ttl
+	clone.group = &singleflight.Group{}
+	return &clone
+}
+
+// WithPrefix returns a copy of the cache that prepends prefix to every key,
+// on top of any prefix the cache already has. Flushing the copy only
+// removes keys under its prefix, which lets several services share one
+// store. The copy shares the underlying store.
+func (c *Cache) WithPrefix(prefix string) *Cache {
+	clone := *c
+	clone.prefix = c.prefix + prefix
+	return &clone
+}
+
+// key returns the store key for a cache key.
+func (c *Cache) key(key string) string {
+	return c.prefix + key
 }
 
 // Has checks if a value exists in the cache for the given key.
 // Returns true if the key exists, false otherwise.
 func (c *Cache) Has(key string) (bool, error) {
-	return c.store.Has(key)
+	return c.HasCtx(context.Background(), key)
+}
+
+// HasCtx is like Has but carries a context for cancellation and deadlines.
+func (c *Cache) HasCtx(ctx context.Context, key string) (bool, error) {
+	if c.negativeTTL > 0 {
+		// negative entries exist in the store but must not be reported
+		data, err := c.GetCtx(ctx, key)
+		return data != nil, err
+	}
+
+	return c.store.HasCtx(ctx, c.key(key))
 }
 
 // Get retrieves the value associated with the given key from the cache.
 // Returns nil if the key does not exist.
 func (c *Cache) Get(key string) (any, error) {
-	return c.store.Get(key)
+	return c.GetCtx(context.Background(), key)
+}
+
+// GetCtx is like Get but carries a context for cancellation and deadlines.
+func (c *Cache) GetCtx(ctx context.Context, key string) (any, error) {
+	data, err := c.store.GetCtx(ctx, c.key(key))
+	if err != nil || isNegative(data) {
+		return nil, err
+	}
+
+	return data, nil
 }
 
 // GetOrDefault retrieves the value associated with the given key.
 // If the key does not exist, it calls the provided defaultFunc to get a default value.
-func (c *Cache) GetOrDefault(key string, defaultFunc func() any) (any, error) {
-	data, err := c.Get(key)
+// Errors returned by defaultFunc are passed to the caller.
+func (c *Cache) GetOrDefault(key string, defaultFunc func() (any, error)) (any, error) {
+	return c.GetOrDefaultCtx(context.Background(), key, defaultFunc)
+}
+
+// GetOrDefaultCtx is like GetOrDefault but carries a context for cancellation and deadlines.
+func (c *Cache) GetOrDefaultCtx(ctx context.Context, key string, defaultFunc func() (any, error)) (any, error) {
+	data, err := c.GetCtx(ctx, key)
 	if err != nil {
 		return nil, err
 	}
@@ -90,56 +172,149 @@ func (c *Cache) GetOrDefault(key string, defaultFunc func() any) (any, error) {
 	if data != nil {
 		return data, nil
 	}
-	return defaultFunc(), nil
+	return defaultFunc()
 }
 
 // Remember retrieves the value for the specified key from the cache.
 // If it does not exist, it calls rememberFunc to generate the value,
 // stores it in the cache with the specified duration, and returns it.
-func (c *Cache) Remember(key string, duration time.Duration, rememberFunc func() any) (any, error) {
-	data, err := c.Get(key)
+// Errors returned by rememberFunc are passed to the caller and nothing is stored.
+func (c *Cache) Remember(key string, duration time.Duration, rememberFunc func() (any, error)) (any, error) {
+	return c.RememberCtx(context.Background(), key, duration, rememberFunc)
+}
+
+// RememberCtx is like Remember but carries a context for cancellation and deadlines.
+//
+// Concurrent misses for the same key are collapsed so that rememberFunc runs
+// once per process; the other callers wait for and share its result. If the
+// store implements store.Locker, the value is also recomputed under a lock
+// held across processes.
+//
+// A caller whose ctx is done stops waiting and gets ctx.Err(), but the value
+// keeps loading for the other callers, so its store calls are not bound by
+// ctx's cancellation.
+func (c *Cache) RememberCtx(ctx context.Context, key string, duration time.Duration, rememberFunc func() (any, error)) (any, error) {
+	data, err := c.lookup(ctx, key)
+	if err != nil || data != nil {
+		return data, err
+	}
+
+	flight := c.group.DoChan(c.key(key), func() (any, error) {
+		return c.remember(context.WithoutCancel(ctx), key, duration, rememberFunc)
+	})
+
+	select {
+	case result := <-flight:
+		return result.Val, result.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// remember generates and stores the value for key, taking the store's
+// lock first when it supports one.
+func (c *Cache) remember(ctx context.Context, key string, duration time.Duration, rememberFunc func() (any, error)) (any, error) {
+	if locker, ok := c.base.(store.Locker); ok {
+		unlock, err := locker.Lock(ctx, c.key(key))
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+
+		// another process may have stored the value while we waited for the lock
+		data, err := c.lookup(ctx, key)
+		if err != nil || data != nil {
+			return data, err
+		}
+	}
+
+	data, err := rememberFunc()
+	if errors.Is(err, ErrNotFound) && c.negativeTTL > 0 {
+		if err := c.store.PutCtx(ctx, c.key(key), negativeValue, c.negativeTTL); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if data != nil {
-		return data, nil
+	if err := c.PutCtx(ctx, key, data, duration); err != nil {
+		return nil, err
 	}
+	return data, nil
+}
 
-	data = rememberFunc()
-	c.Put(key, data, duration)
+// lookup reads key for Remember. It returns ErrNotFound for negative
+// entries, and nil data without an error on a miss.
+func (c *Cache) lookup(ctx context.Context, key string) (any, error) {
+	data, err := c.store.GetCtx(ctx, c.key(key))
+	if err != nil {
+		return nil, err
+	}
+
+	if isNegative(data) {
+		return nil, ErrNotFound
+	}
 	return data, nil
 }
 
+// isNegative reports whether data is a negative cache entry.
+func isNegative(data any) bool {
+	switch value := data.(type) {
+	case string:
+		return value == negativeValue
+	case []byte:
+		return string(value) == negativeValue
+	}
+	return false
+}
+
 // RememberForever retrieves the value for the specified key from the cache.
 // If it does not exist, it calls rememberFunc to generate the value,
 // and stores it indefinitely in the cache.
-func (c *Cache) RememberForever(key string, rememberFunc func() any) (any, error) {
-	return c.Remember(key, ForeverDuration, rememberFunc)
+func (c *Cache) RememberForever(key string, rememberFunc func() (any, error)) (any, error) {
+	return c.RememberForeverCtx(context.Background(), key, rememberFunc)
+}
+
+// RememberForeverCtx is like RememberForever but carries a context for cancellation and deadlines.
+func (c *Cache) RememberForeverCtx(ctx context.Context, key string, rememberFunc func() (any, error)) (any, error) {
+	return c.RememberCtx(ctx, key, ForeverDuration, rememberFunc)
 }
 
 // Pull retrieves the value for the specified key from the cache and
 // removes it from the cache. Returns the value or nil if it doesn't exist.
 func (c *Cache) Pull(key string) (any, error) {
-	data, err := c.Get(key)
+	return c.PullCtx(context.Background(), key)
+}
+
+// PullCtx is like Pull but carries a context for cancellation and deadlines.
+func (c *Cache) PullCtx(ctx context.Context, key string) (any, error) {
+	data, err := c.GetCtx(ctx, key)
 	if err != nil {
 		return nil, err
 	}
 
-	c.Forget(key)
+	c.ForgetCtx(ctx, key)
 	return data, nil
 }
 
 // PullOrDefault retrieves the value for the specified key from the cache.
 // If it does not exist, it calls defaultFunc to get a default value,
 // removes the key from the cache, and returns the value.
-func (c *Cache) PullOrDefault(key string, defaultFunc func() any) (any, error) {
-	data, err := c.GetOrDefault(key, defaultFunc)
+// Errors returned by defaultFunc are passed to the caller.
+func (c *Cache) PullOrDefault(key string, defaultFunc func() (any, error)) (any, error) {
+	return c.PullOrDefaultCtx(context.Background(), key, defaultFunc)
+}
+
+// PullOrDefaultCtx is like PullOrDefault but carries a context for cancellation and deadlines.
+func (c *Cache) PullOrDefaultCtx(ctx context.Context, key string, defaultFunc func() (any, error)) (any, error) {
+	data, err := c.GetOrDefaultCtx(ctx, key, defaultFunc)
 	if err != nil {
 		return nil, err
 	}
 
-	c.Forget(key)
+	c.ForgetCtx(ctx, key)
 	return data, nil
 }
 
@@ -147,36 +322,245 @@ func (c *Cache) PullOrDefault(key string, defaultFunc func() any) (any, error) {
 // with the provided duration. If the duration is zero, the data is
 // stored indefinitely.
 func (c *Cache) Put(key string, data any, duration time.Duration) error {
-	return c.store.Put(key, data, duration)
+	return c.PutCtx(context.Background(), key, data, duration)
+}
+
+// PutCtx is like Put but carries a context for cancellation and deadlines.
+func (c *Cache) PutCtx(ctx context.Context, key string, data any, duration time.Duration) error {
+	return c.store.PutCtx(ctx, c.key(key), data, duration)
 }
 
 // Forever stores the given data in the cache under the specified key
 // indefinitely, ignoring the duration.
 func (c *Cache) Forever(key string, data any) {
-	c.Put(key, data, ForeverDuration)
+	c.ForeverCtx(context.Background(), key, data)
+}
+
+// ForeverCtx is like Forever but carries a context for cancellation and deadlines.
+func (c *Cache) ForeverCtx(ctx context.Context, key string, data any) {
+	c.PutCtx(ctx, key, data, ForeverDuration)
 }
 
 // Add stores the given data in the cache under the specified key
-// only if the key does not already exist. If the key exists, no action is taken.
-func (c *Cache) Add(key string, data any, duration time.Duration) error {
-	has, err := c.Has(key)
+// only if the key does not already exist, and reports whether it was stored.
+// The check and the write are atomic when the store implements store.Adder.
+func (c *Cache) Add(key string, data any, duration time.Duration) (bool, error) {
+	return c.AddCtx(context.Background(), key, data, duration)
+}
+
+// AddCtx is like Add but carries a context for cancellation and deadlines.
+func (c *Cache) AddCtx(ctx context.Context, key string, data any, duration time.Duration) (bool, error) {
+	if adder, ok := c.base.(store.Adder); ok {
+		return adder.PutIfAbsent(ctx, c.key(key), data, duration)
+	}
+
+	has, err := c.HasCtx(ctx, key)
+	if err != nil || has {
+		return false, err
+	}
+
+	if err := c.store.PutCtx(ctx, c.key(key), data, duration); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Increment atomically adds by to the integer stored under key and returns
+// the new value. A missing key starts at zero and is stored with the given
+// duration; the expiry of an existing key is left untouched.
+func (c *Cache) Increment(key string, by int64, duration time.Duration) (int64, error) {
+	return c.IncrementCtx(context.Background(), key, by, duration)
+}
+
+// IncrementCtx is like Increment but carries a context for cancellation and deadlines.
+func (c *Cache) IncrementCtx(ctx context.Context, key string, by int64, duration time.Duration) (int64, error) {
+	counter, err := c.counter()
+	if err != nil {
+		return 0, err
+	}
+	return counter.Increment(ctx, c.key(key), by, duration)
+}
+
+// Decrement atomically subtracts by from the integer stored under key and
+// returns the new value. See Increment.
+func (c *Cache) Decrement(key string, by int64, duration time.Duration) (int64, error) {
+	return c.DecrementCtx(context.Background(), key, by, duration)
+}
+
+// DecrementCtx is like Decrement but carries a context for cancellation and deadlines.
+func (c *Cache) DecrementCtx(ctx context.Context, key string, by int64, duration time.Duration) (int64, error) {
+	return c.IncrementCtx(ctx, key, -by, duration)
+}
+
+// IncrementFloat atomically adds by to the floating point value stored under
+// key and returns the new value. See Increment.
+func (c *Cache) IncrementFloat(key string, by float64, duration time.Duration) (float64, error) {
+	return c.IncrementFloatCtx(context.Background(), key, by, duration)
+}
+
+// IncrementFloatCtx is like IncrementFloat but carries a context for cancellation and deadlines.
+func (c *Cache) IncrementFloatCtx(ctx context.Context, key string, by float64, duration time.Duration) (float64, error) {
+	counter, err := c.counter()
+	if err != nil {
+		return 0, err
+	}
+	return counter.IncrementFloat(ctx, c.key(key), by, duration)
+}
+
+// DecrementFloat atomically subtracts by from the floating point value stored
+// under key and returns the new value. See Increment.
+func (c *Cache) DecrementFloat(key string, by float64, duration time.Duration) (float64, error) {
+	return c.DecrementFloatCtx(context.Background(), key, by, duration)
+}
+
+// DecrementFloatCtx is like DecrementFloat but carries a context for cancellation and deadlines.
+func (c *Cache) DecrementFloatCtx(ctx context.Context, key string, by float64, duration time.Duration) (float64, error) {
+	return c.IncrementFloatCtx(ctx, key, -by, duration)
+}
+
+// counter returns the store as a store.Counter.
+func (c *Cache) counter() (store.Counter, error) {
+	counter, ok := c.base.(store.Counter)
+	if !ok {
+		return nil, fmt.Errorf("cache store does not support counters: %w", errors.ErrUnsupported)
+	}
+	return counter, nil
+}
+
+// GetMany retrieves the values for the given keys in as few round-trips as
+// the store allows. Keys that do not exist are left out of the result.
+func (c *Cache) GetMany(keys []string) (map[string]any, error) {
+	return c.GetManyCtx(context.Background(), keys)
+}
+
+// GetManyCtx is like GetMany but carries a context for cancellation and deadlines.
+func (c *Cache) GetManyCtx(ctx context.Context, keys []string) (map[string]any, error) {
+	batch, ok := c.base.(store.BatchStore)
+	if !ok {
+		items := make(map[string]any, len(keys))
+		for _, key := range keys {
+			data, err := c.GetCtx(ctx, key)
+			if err != nil {
+				return nil, err
+			}
+
+			if data != nil {
+				items[key] = data
+			}
+		}
+		return items, nil
+	}
+
+	storeKeys := make([]string, len(keys))
+	for i, key := range keys {
+		storeKeys[i] = c.key(key)
+	}
+
+	storeItems, err := batch.GetMany(ctx, storeKeys)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if !has {
-		return c.store.Put(key, data, duration)
+	items := make(map[string]any, len(storeItems))
+	for i, key := range keys {
+		data, ok := storeItems[storeKeys[i]]
+		if ok && !isNegative(data) {
+			items[key] = data
+		}
 	}
+	return items, nil
+}
+
+// PutMany stores every item in the cache under its key with the provided duration.
+func (c *Cache) PutMany(items map[string]any, duration time.Duration) error {
+	return c.PutManyCtx(context.Background(), items, duration)
+}
 
+// PutManyCtx is like PutMany but carries a context for cancellation and deadlines.
+func (c *Cache) PutManyCtx(ctx context.Context, items map[string]any, duration time.Duration) error {
+	if batch, ok := c.base.(store.BatchStore); ok {
+		storeItems := make(map[string]any, len(items))
+		for key, data := range items {
+			storeItems[c.key(key)] = data
+		}
+		return batch.PutMany(ctx, storeItems, duration)
+	}
+
+	for key, data := range items {
+		if err := c.PutCtx(ctx, key, data, duration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForgetMany removes the values associated with the given keys from the cache.
+func (c *Cache) ForgetMany(keys []string) error {
+	return c.ForgetManyCtx(context.Background(), keys)
+}
+
+// ForgetManyCtx is like ForgetMany but carries a context for cancellation and deadlines.
+func (c *Cache) ForgetManyCtx(ctx context.Context, keys []string) error {
+	if batch, ok := c.base.(store.BatchStore); ok {
+		storeKeys := make([]string, len(keys))
+		for i, key := range keys {
+			storeKeys[i] = c.key(key)
+		}
+		return batch.DeleteMany(ctx, storeKeys)
+	}
+
+	for _, key := range keys {
+		if err := c.ForgetCtx(ctx, key); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // Forget removes the value associated with the specified key from the cache.
 func (c *Cache) Forget(key string) error {
-	return c.store.Delete(key)
+	return c.ForgetCtx(context.Background(), key)
 }
 
-// Flush empties the cache.
+// ForgetCtx is like Forget but carries a context for cancellation and deadlines.
+func (c *Cache) ForgetCtx(ctx context.Context, key string) error {
+	return c.store.DeleteCtx(ctx, c.key(key))
+}
+
+// Flush empties the cache. On a cache with a prefix, only keys under the
+// prefix are removed.
 func (c *Cache) Flush() error {
-	return c.store.Flush()
+	return c.FlushCtx(context.Background())
+}
+
+// FlushCtx is like Flush but carries a context for cancellation and deadlines.
+func (c *Cache) FlushCtx(ctx context.Context) error {
+	if c.prefix == "" {
+		return c.store.FlushCtx(ctx)
+	}
+
+	flusher, ok := c.base.(store.PrefixFlusher)
+	if !ok {
+		return fmt.Errorf("cache store does not support flushing by prefix: %w", errors.ErrUnsupported)
+	}
+	return flusher.FlushPrefix(ctx, c.prefix)
+}
+
+// Close stops any background work of the underlying store and releases its
+// resources, such as network connections and open files. Copies made with
+// WithPrefix or WithNegativeTTL share the store, so closing any of them
+// closes it for all. The cache must not be used after it is closed.
+func (c *Cache) Close() error {
+	return c.store.Close()
+}
+
+// Ping reports whether the underlying store is reachable, for stores that
+// implement store.HealthChecker. Other stores are always reported healthy.
+func (c *Cache) Ping(ctx context.Context) error {
+	checker, ok := c.base.(store.HealthChecker)
+	if !ok {
+		return nil
+	}
+	return checker.Ping(ctx)
 }
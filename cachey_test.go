@@ -1,10 +1,19 @@
 package cachey
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/codemaestro64/cachey/store"
+	"github.com/codemaestro64/cachey/store/memory"
+	"github.com/codemaestro64/cachey/store/redis"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func testCacheGetOrDefault(t *testing.T, cache *Cache) {
@@ -12,8 +21,8 @@ func testCacheGetOrDefault(t *testing.T, cache *Cache) {
 	defaultVal := "defaultVal"
 
 	// 1. Try to get the value from cache, which should not exist initially.
-	gotVal, err := cache.GetOrDefault(key, func() any {
-		return defaultVal
+	gotVal, err := cache.GetOrDefault(key, func() (any, error) {
+		return defaultVal, nil
 	})
 	assert.NoError(t, err)
 	assert.Equal(t, defaultVal, gotVal) // Should return default value, since key doesn't exist
@@ -28,8 +37,8 @@ func testCacheGetOrDefault(t *testing.T, cache *Cache) {
 	assert.NoError(t, err)
 
 	// 4. Call GetOrDefault again, and it should return the cached value now
-	gotValAgain, err := cache.GetOrDefault(key, func() any {
-		return defaultVal
+	gotValAgain, err := cache.GetOrDefault(key, func() (any, error) {
+		return defaultVal, nil
 	})
 	assert.NoError(t, err)
 	assert.Equal(t, "storedValue", gotValAgain) // Should return the cached value
@@ -71,8 +80,8 @@ func testCachePullOrDefault(t *testing.T, cache *Cache) {
 	defaultVal := "defaultVal"
 
 	// 1. First, call PullOrDefault when the key does not exist in the cache.
-	pulledVal, err := cache.PullOrDefault(key, func() any {
-		return defaultVal
+	pulledVal, err := cache.PullOrDefault(key, func() (any, error) {
+		return defaultVal, nil
 	})
 	assert.NoError(t, err)
 	assert.Equal(t, defaultVal, pulledVal)
@@ -87,8 +96,8 @@ func testCachePullOrDefault(t *testing.T, cache *Cache) {
 	assert.NoError(t, err)
 
 	// 4. Call PullOrDefault again, it should return the cached value and remove the key from the cache
-	pulledValAgain, err := cache.PullOrDefault(key, func() any {
-		return "newDefault"
+	pulledValAgain, err := cache.PullOrDefault(key, func() (any, error) {
+		return "newDefault", nil
 	})
 	assert.NoError(t, err)
 	assert.Equal(t, "storedValue", pulledValAgain)
@@ -103,8 +112,8 @@ func testCacheRemember(t *testing.T, cache *Cache) {
 	key := "key"
 	rememberedValue := "value"
 
-	val, err := cache.Remember(key, time.Second, func() any {
-		return rememberedValue
+	val, err := cache.Remember(key, time.Second, func() (any, error) {
+		return rememberedValue, nil
 	})
 	assert.NoError(t, err)
 
@@ -128,12 +137,160 @@ func testCacheRemember(t *testing.T, cache *Cache) {
 	assert.Equal(t, false, has)
 }
 
+func testCacheRememberConcurrent(t *testing.T, cache *Cache) {
+	key := "hotKey"
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			val, err := cache.Remember(key, time.Minute, func() (any, error) {
+				calls.Add(1)
+				time.Sleep(100 * time.Millisecond)
+				return "computed", nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, "computed", val)
+		}()
+	}
+	wg.Wait()
+
+	// ensure concurrent misses were collapsed into a single computation
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func testCacheRememberCanceled(t *testing.T, cache *Cache) {
+	key := "canceledKey"
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := cache.RememberCtx(ctx, key, time.Minute, func() (any, error) {
+			close(started)
+			<-release
+			return "computed", nil
+		})
+		firstErr <- err
+	}()
+	<-started
+
+	secondVal := make(chan any, 1)
+	go func() {
+		val, err := cache.Remember(key, time.Minute, func() (any, error) {
+			t.Error("loader should run once for concurrent misses")
+			return nil, nil
+		})
+		assert.NoError(t, err)
+		secondVal <- val
+	}()
+
+	// the first caller gives up without failing the load it started
+	cancel()
+	assert.ErrorIs(t, <-firstErr, context.Canceled)
+
+	close(release)
+	assert.Equal(t, "computed", <-secondVal)
+
+	val, err := cache.Get(key)
+	assert.NoError(t, err)
+	assert.Equal(t, "computed", val)
+}
+
+func testCacheRememberError(t *testing.T, cache *Cache) {
+	key := "failingKey"
+	loadErr := errors.New("database unavailable")
+
+	_, err := cache.Remember(key, time.Minute, func() (any, error) {
+		return nil, loadErr
+	})
+	assert.ErrorIs(t, err, loadErr)
+
+	// ensure the failed result was not cached
+	has, err := cache.Has(key)
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	_, err = cache.GetOrDefault(key, func() (any, error) {
+		return nil, loadErr
+	})
+	assert.ErrorIs(t, err, loadErr)
+}
+
+func testCacheNegativeTTL(t *testing.T, cache *Cache) {
+	key := "missingKey"
+	negativeCache := cache.WithNegativeTTL(time.Second)
+	var calls int
+
+	loader := func() (any, error) {
+		calls++
+		return nil, ErrNotFound
+	}
+
+	_, err := negativeCache.Remember(key, time.Minute, loader)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// the not-found result is remembered without calling the loader again
+	_, err = negativeCache.Remember(key, time.Minute, loader)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 1, calls)
+
+	// negative entries are invisible to Get and Has
+	cachedVal, err := negativeCache.Get(key)
+	assert.NoError(t, err)
+	assert.Nil(t, cachedVal)
+
+	has, err := negativeCache.Has(key)
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	// wait for the negative entry to expire
+	time.Sleep(2 * time.Second)
+
+	_, err = negativeCache.Remember(key, time.Minute, loader)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 2, calls)
+
+	// a load in flight on the cache without a negative TTL is not shared
+	// with the copy, which must still remember its own not-found result
+	sharedKey := "sharedMissingKey"
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := cache.Remember(sharedKey, time.Minute, func() (any, error) {
+			close(started)
+			<-release
+			return nil, ErrNotFound
+		})
+		assert.ErrorIs(t, err, ErrNotFound)
+	}()
+	<-started
+
+	_, err = negativeCache.Remember(sharedKey, time.Minute, loader)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 3, calls)
+
+	close(release)
+	<-done
+
+	_, err = negativeCache.Remember(sharedKey, time.Minute, loader)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 3, calls)
+}
+
 func testCacheAdd(t *testing.T, cache *Cache) {
 	key := "key"
 	val := "val"
 
-	err := cache.Add(key, val, ForeverDuration)
+	added, err := cache.Add(key, val, ForeverDuration)
 	assert.NoError(t, err)
+	assert.True(t, added)
 
 	// ensure item was added to cache
 	cachedVal, err := cache.Get(key)
@@ -150,8 +307,9 @@ func testCacheAdd(t *testing.T, cache *Cache) {
 
 	// add new val with same key
 	val2 := "val2"
-	err = cache.Add(key1, val2, ForeverDuration)
+	added, err = cache.Add(key1, val2, ForeverDuration)
 	assert.NoError(t, err)
+	assert.False(t, added)
 
 	// get val
 	cachedVal1, err := cache.Get(key1)
@@ -161,6 +319,230 @@ func testCacheAdd(t *testing.T, cache *Cache) {
 	assert.Equal(t, val1, cachedVal1)
 }
 
+func testCacheAddConcurrent(t *testing.T, cache *Cache) {
+	key := "addKey"
+	var added atomic.Int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ok, err := cache.Add(key, "val", time.Minute)
+			assert.NoError(t, err)
+			if ok {
+				added.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// ensure exactly one caller won the race
+	assert.Equal(t, int32(1), added.Load())
+}
+
+func testCacheIncrement(t *testing.T, cache *Cache) {
+	key := "counter"
+
+	// missing counters start at zero
+	val, err := cache.Increment(key, 5, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), val)
+
+	val, err = cache.Decrement(key, 2, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), val)
+
+	floatKey := "floatCounter"
+	floatVal, err := cache.IncrementFloat(floatKey, 1.5, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, floatVal)
+
+	floatVal, err = cache.DecrementFloat(floatKey, 0.25, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.25, floatVal)
+
+	// concurrent increments are not lost
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := cache.Increment(key, 1, time.Minute)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	val, err = cache.Increment(key, 0, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(53), val)
+}
+
+func testCacheBatch(t *testing.T, cache *Cache) {
+	items := map[string]any{
+		"batch1": "val1",
+		"batch2": "val2",
+		"batch3": "val3",
+	}
+
+	err := cache.PutMany(items, time.Minute)
+	assert.NoError(t, err)
+
+	// missing keys are left out of the result
+	cachedItems, err := cache.GetMany([]string{"batch1", "batch2", "batch3", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, items, cachedItems)
+
+	err = cache.ForgetMany([]string{"batch1", "batch2"})
+	assert.NoError(t, err)
+
+	cachedItems, err = cache.GetMany([]string{"batch1", "batch2", "batch3"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"batch3": "val3"}, cachedItems)
+}
+
+func testCacheTags(t *testing.T, cache *Cache) {
+	users := cache.Tags("users")
+	user42 := cache.Tags("users", "user:42")
+
+	err := users.Put("count", "10", time.Minute)
+	assert.NoError(t, err)
+
+	err = user42.Put("profile", "Ada", time.Minute)
+	assert.NoError(t, err)
+
+	err = cache.Put("untagged", "val", time.Minute)
+	assert.NoError(t, err)
+
+	// entries are read back through the same tags
+	cachedVal, err := user42.Get("profile")
+	assert.NoError(t, err)
+	assert.Equal(t, "Ada", cachedVal)
+
+	// tagged entries don't collide with untagged keys
+	cachedVal, err = cache.Get("profile")
+	assert.NoError(t, err)
+	assert.Nil(t, cachedVal)
+
+	// flushing a tag invalidates every entry written under it
+	err = cache.Tags("user:42").Flush()
+	assert.NoError(t, err)
+
+	has, err := user42.Has("profile")
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	// entries under other tags and untagged entries survive
+	cachedVal, err = users.Get("count")
+	assert.NoError(t, err)
+	assert.Equal(t, "10", cachedVal)
+
+	cachedVal, err = cache.Get("untagged")
+	assert.NoError(t, err)
+	assert.Equal(t, "val", cachedVal)
+
+	// Remember works under tags
+	val, err := user42.Remember("profile", time.Minute, func() (any, error) {
+		return "Grace", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Grace", val)
+
+	// counters and Forever work under tags
+	visits, err := user42.Increment("visits", 3, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), visits)
+
+	visits, err = user42.Decrement("visits", 1, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), visits)
+
+	score, err := user42.IncrementFloat("score", 1.5, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, score)
+
+	score, err = user42.DecrementFloat("score", 0.5, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, score)
+
+	user42.Forever("name", "Grace")
+	cachedVal, err = user42.Get("name")
+	assert.NoError(t, err)
+	assert.Equal(t, "Grace", cachedVal)
+
+	// tag order is part of the namespace
+	cachedVal, err = cache.Tags("user:42", "users").Get("name")
+	assert.NoError(t, err)
+	assert.Nil(t, cachedVal)
+
+	err = users.Flush()
+	assert.NoError(t, err)
+
+	has, err = user42.Has("profile")
+	assert.NoError(t, err)
+	assert.False(t, has)
+}
+
+func testCachePrefix(t *testing.T, cache *Cache) {
+	serviceA := cache.WithPrefix("svc-a:")
+	serviceB := cache.WithPrefix("svc-b:")
+
+	err := serviceA.Put("key", "a", time.Minute)
+	assert.NoError(t, err)
+
+	err = serviceB.Put("key", "b", time.Minute)
+	assert.NoError(t, err)
+
+	// the same key doesn't collide across prefixes
+	cachedVal, err := serviceA.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", cachedVal)
+
+	cachedVal, err = cache.Get("svc-b:key")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", cachedVal)
+
+	// flushing a prefixed cache only removes its own keys
+	err = serviceA.Flush()
+	assert.NoError(t, err)
+
+	has, err := serviceA.Has("key")
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	has, err = serviceB.Has("key")
+	assert.NoError(t, err)
+	assert.True(t, has)
+}
+
+func testCacheContext(t *testing.T, cache *Cache) {
+	key := "ctxKey"
+
+	err := cache.PutCtx(context.Background(), key, "val", ForeverDuration)
+	assert.NoError(t, err)
+
+	cachedVal, err := cache.GetCtx(context.Background(), key)
+	assert.NoError(t, err)
+	assert.Equal(t, "val", cachedVal)
+
+	// a cancelled context must abort the call without touching the store
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = cache.GetCtx(ctx, key)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	err = cache.ForgetCtx(ctx, key)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	has, err := cache.Has(key)
+	assert.NoError(t, err)
+	assert.True(t, has)
+}
+
 func runAllTests(t *testing.T, cache *Cache) {
 	t.Run("Test GetOrDefault", func(t *testing.T) {
 		testCacheGetOrDefault(t, cache)
@@ -178,9 +560,49 @@ func runAllTests(t *testing.T, cache *Cache) {
 		testCacheRemember(t, cache)
 	})
 
+	t.Run("Test Remember Concurrent", func(t *testing.T) {
+		testCacheRememberConcurrent(t, cache)
+	})
+
+	t.Run("Test Remember Canceled", func(t *testing.T) {
+		testCacheRememberCanceled(t, cache)
+	})
+
+	t.Run("Test Remember Error", func(t *testing.T) {
+		testCacheRememberError(t, cache)
+	})
+
+	t.Run("Test Negative TTL", func(t *testing.T) {
+		testCacheNegativeTTL(t, cache)
+	})
+
 	t.Run("Test Add", func(t *testing.T) {
 		testCacheAdd(t, cache)
 	})
+
+	t.Run("Test Add Concurrent", func(t *testing.T) {
+		testCacheAddConcurrent(t, cache)
+	})
+
+	t.Run("Test Increment", func(t *testing.T) {
+		testCacheIncrement(t, cache)
+	})
+
+	t.Run("Test Batch", func(t *testing.T) {
+		testCacheBatch(t, cache)
+	})
+
+	t.Run("Test Tags", func(t *testing.T) {
+		testCacheTags(t, cache)
+	})
+
+	t.Run("Test Prefix", func(t *testing.T) {
+		testCachePrefix(t, cache)
+	})
+
+	t.Run("Test Context", func(t *testing.T) {
+		testCacheContext(t, cache)
+	})
 }
 
 func TestMemoryCache(t *testing.T) {
@@ -188,6 +610,258 @@ func TestMemoryCache(t *testing.T) {
 	runAllTests(t, memoryCache)
 }
 
+// countingStore decorates a store and counts the reads that reach it.
+type countingStore struct {
+	store.Store
+	gets atomic.Int64
+	init error
+}
+
+func (s *countingStore) Init() error {
+	if s.init != nil {
+		return s.init
+	}
+	return s.Store.Init()
+}
+
+func (s *countingStore) Get(key string) (any, error) {
+	s.gets.Add(1)
+	return s.Store.Get(key)
+}
+
+// adderStore is a plain store, without the Ctx methods, that implements
+// store.Adder and counts the calls to it.
+type adderStore struct {
+	store.Store
+	adds atomic.Int64
+}
+
+func (s *adderStore) PutIfAbsent(ctx context.Context, key string, data any, duration time.Duration) (bool, error) {
+	s.adds.Add(1)
+	return false, nil
+}
+
+func TestCache_AddUsesAdder(t *testing.T) {
+	adder := &adderStore{Store: memory.NewMemoryStore()}
+	cache, err := NewFromStore(adder)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	added, err := cache.Add("key", "value", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, added)
+	assert.Equal(t, int64(1), adder.adds.Load(), "Add should use the store's PutIfAbsent")
+}
+
+// capableStore is a plain store, without the Ctx methods, that implements
+// the optional interfaces by delegating to a memory store, as a decorator
+// would, and records which of them were used.
+type capableStore struct {
+	store.Store
+	inner *memory.MemoryStore
+
+	mu   sync.Mutex
+	used []string
+}
+
+func newCapableStore() *capableStore {
+	inner := memory.NewMemoryStore().(*memory.MemoryStore)
+	return &capableStore{Store: inner, inner: inner}
+}
+
+func (s *capableStore) use(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used = append(s.used, name)
+}
+
+func (s *capableStore) Lock(ctx context.Context, key string) (func(), error) {
+	s.use("Lock")
+	return func() {}, nil
+}
+
+func (s *capableStore) Increment(ctx context.Context, key string, by int64, duration time.Duration) (int64, error) {
+	s.use("Increment")
+	return s.inner.Increment(ctx, key, by, duration)
+}
+
+func (s *capableStore) IncrementFloat(ctx context.Context, key string, by float64, duration time.Duration) (float64, error) {
+	s.use("IncrementFloat")
+	return s.inner.IncrementFloat(ctx, key, by, duration)
+}
+
+func (s *capableStore) GetMany(ctx context.Context, keys []string) (map[string]any, error) {
+	s.use("GetMany")
+	return s.inner.GetMany(ctx, keys)
+}
+
+func (s *capableStore) PutMany(ctx context.Context, items map[string]any, duration time.Duration) error {
+	s.use("PutMany")
+	return s.inner.PutMany(ctx, items, duration)
+}
+
+func (s *capableStore) DeleteMany(ctx context.Context, keys []string) error {
+	s.use("DeleteMany")
+	return s.inner.DeleteMany(ctx, keys)
+}
+
+func (s *capableStore) FlushPrefix(ctx context.Context, prefix string) error {
+	s.use("FlushPrefix")
+	return s.inner.FlushPrefix(ctx, prefix)
+}
+
+func TestNewFromStore_OptionalInterfaces(t *testing.T) {
+	capable := newCapableStore()
+	cache, err := NewFromStore(capable)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	_, err = cache.Remember("remembered", time.Minute, func() (any, error) {
+		return "value", nil
+	})
+	assert.NoError(t, err)
+
+	val, err := cache.Increment("counter", 2, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), val)
+
+	floatVal, err := cache.IncrementFloat("float", 0.5, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, floatVal)
+
+	assert.NoError(t, cache.PutMany(map[string]any{"a": 1}, time.Minute))
+	_, err = cache.GetMany([]string{"a"})
+	assert.NoError(t, err)
+	assert.NoError(t, cache.ForgetMany([]string{"a"}))
+
+	// a prefixed flush only removes the keys under the prefix
+	prefixed := cache.WithPrefix("svc:")
+	require.NoError(t, prefixed.Put("key", "value", time.Minute))
+	assert.NoError(t, prefixed.Flush())
+
+	has, err := cache.Has("counter")
+	assert.NoError(t, err)
+	assert.True(t, has, "Keys outside the prefix should survive")
+
+	assert.Equal(t, []string{
+		"Lock", "Increment", "IncrementFloat",
+		"PutMany", "GetMany", "DeleteMany", "FlushPrefix",
+	}, capable.used)
+}
+
+func TestNewFromStore(t *testing.T) {
+	counting := &countingStore{Store: memory.NewMemoryStore()}
+
+	cache, err := NewFromStore(counting, memory.WithMaxItems(10))
+	assert.Error(t, err, "Options should be applied to the given store")
+	assert.Nil(t, cache)
+
+	memoryStore := memory.NewMemoryStore()
+	require.NoError(t, memory.WithMaxItems(10)(memoryStore))
+	counting = &countingStore{Store: memoryStore}
+
+	cache, err = NewFromStore(counting)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	assert.Same(t, counting, cache.Store())
+
+	require.NoError(t, cache.Put("key", "value", time.Minute))
+	val, err := cache.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, int64(1), counting.gets.Load(), "Reads should go through the decorator")
+
+	_, err = NewFromStore(&countingStore{Store: memory.NewMemoryStore(), init: errors.New("unreachable")})
+	assert.Error(t, err, "Init errors should be returned")
+
+	_, err = NewFromStore(nil)
+	assert.Error(t, err)
+}
+
+func TestCache_Close(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	redisCache, err := New(RedisStore,
+		redis.WithAddress(mr.Addr()),
+		redis.WithReadTimeout(5*time.Second),
+		redis.WithWriteTimeout(5*time.Second),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, redisCache.Put("key", "value", time.Minute))
+	require.NoError(t, redisCache.Close())
+
+	_, err = redisCache.Get("key")
+	assert.Error(t, err, "A closed cache should not reach the store")
+}
+
 func TestRedisCache(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	redisCache, err := New(RedisStore,
+		redis.WithAddress(mr.Addr()),
+		redis.WithReadTimeout(5*time.Second),
+		redis.WithWriteTimeout(5*time.Second),
+	)
+	require.NoError(t, err)
+	defer redisCache.Close()
+
+	// miniredis only expires keys when told to, so tests that wait for
+	// expiry are covered by the memory cache.
+	t.Run("Test GetOrDefault", func(t *testing.T) {
+		testCacheGetOrDefault(t, redisCache)
+	})
+
+	t.Run("Test Pull", func(t *testing.T) {
+		testCachePull(t, redisCache)
+	})
+
+	t.Run("Test PullOrDefault", func(t *testing.T) {
+		testCachePullOrDefault(t, redisCache)
+	})
+
+	t.Run("Test Remember Concurrent", func(t *testing.T) {
+		testCacheRememberConcurrent(t, redisCache)
+	})
+
+	t.Run("Test Remember Canceled", func(t *testing.T) {
+		testCacheRememberCanceled(t, redisCache)
+	})
+
+	t.Run("Test Remember Error", func(t *testing.T) {
+		testCacheRememberError(t, redisCache)
+	})
 
+	t.Run("Test Add", func(t *testing.T) {
+		testCacheAdd(t, redisCache)
+	})
+
+	t.Run("Test Add Concurrent", func(t *testing.T) {
+		testCacheAddConcurrent(t, redisCache)
+	})
+
+	t.Run("Test Increment", func(t *testing.T) {
+		testCacheIncrement(t, redisCache)
+	})
+
+	t.Run("Test Batch", func(t *testing.T) {
+		testCacheBatch(t, redisCache)
+	})
+
+	t.Run("Test Tags", func(t *testing.T) {
+		testCacheTags(t, redisCache)
+	})
+
+	t.Run("Test Prefix", func(t *testing.T) {
+		testCachePrefix(t, redisCache)
+	})
+
+	t.Run("Test Context", func(t *testing.T) {
+		testCacheContext(t, redisCache)
+	})
 }
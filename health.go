@@ -0,0 +1,39 @@
+package cachey
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Health statuses reported by HealthHandler.
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+)
+
+// healthResponse is the JSON body written by HealthHandler.
+type healthResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthHandler returns an http.Handler that pings the cache's store and
+// reports the result as JSON, for use as a readiness probe. It responds
+// with 200 and {"status":"ok"} when the store is healthy, and with 503 and
+// the error otherwise. The ping is bound to the request's context.
+func HealthHandler(c *Cache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := healthResponse{Status: HealthStatusOK}
+		code := http.StatusOK
+
+		if err := c.Ping(r.Context()); err != nil {
+			response = healthResponse{Status: HealthStatusUnavailable, Error: err.Error()}
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(response)
+	})
+}
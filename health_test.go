@@ -0,0 +1,84 @@
+package cachey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/codemaestro64/cachey/store"
+	"github.com/codemaestro64/cachey/store/memory"
+	"github.com/codemaestro64/cachey/store/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkHealth(t *testing.T, cache *Cache) (int, healthResponse) {
+	recorder := httptest.NewRecorder()
+	HealthHandler(cache).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	var response healthResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+	return recorder.Code, response
+}
+
+func TestHealthHandler(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	cache, err := New(RedisStore,
+		redis.WithAddress(mr.Addr()),
+		redis.WithMaxRetries(0),
+		redis.WithReadTimeout(5*time.Second),
+		redis.WithWriteTimeout(5*time.Second),
+	)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	code, response := checkHealth(t, cache)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthStatusOK, response.Status)
+	assert.Empty(t, response.Error)
+
+	mr.Close()
+
+	code, response = checkHealth(t, cache)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, HealthStatusUnavailable, response.Status)
+	assert.NotEmpty(t, response.Error)
+}
+
+func TestCache_Ping(t *testing.T) {
+	cache, err := New(MemoryStore)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	assert.NoError(t, cache.WithPrefix("svc:").Ping(context.Background()))
+}
+
+// downStore is a plain store, without the Ctx methods, whose health check
+// always fails.
+type downStore struct {
+	store.Store
+}
+
+func (downStore) Ping(ctx context.Context) error {
+	return errors.New("down")
+}
+
+func TestHealthHandler_PlainStore(t *testing.T) {
+	cache, err := NewFromStore(downStore{Store: memory.NewMemoryStore()})
+	require.NoError(t, err)
+	defer cache.Close()
+
+	assert.EqualError(t, cache.Ping(context.Background()), "down")
+
+	code, response := checkHealth(t, cache)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "down", response.Error)
+}
@@ -0,0 +1,90 @@
+package cachey
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/codemaestro64/cachey/store/bolt"
+	"github.com/codemaestro64/cachey/store/file"
+	"github.com/codemaestro64/cachey/store/memcached"
+	"github.com/codemaestro64/cachey/store/memory"
+	"github.com/codemaestro64/cachey/store/redis"
+	"github.com/codemaestro64/cachey/store/sql"
+)
+
+// Registry maps store names to store constructors. It is safe for
+// concurrent use.
+type Registry struct {
+	mu     sync.RWMutex
+	stores map[string]StoreConstructorFunc
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{stores: make(map[string]StoreConstructorFunc)}
+}
+
+// DefaultRegistry holds the built-in stores. It is used by New and
+// RegisterStore.
+var DefaultRegistry = newDefaultRegistry()
+
+func newDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.stores[MemoryStore] = memory.NewMemoryStore
+	r.stores[RedisStore] = redis.NewRedisStore
+	r.stores[FileStore] = file.NewFileStore
+	r.stores[MemcachedStore] = memcached.NewMemcachedStore
+	r.stores[BoltStore] = bolt.NewBoltStore
+	r.stores[SQLStore] = sql.NewSQLStore
+	return r
+}
+
+// Register adds a store constructor under the given name. It returns an
+// error if the name is already registered; use Unregister first to
+// replace a store.
+func (r *Registry) Register(storeName string, constructorFunc StoreConstructorFunc) error {
+	if constructorFunc == nil {
+		return errors.New("cache store constructor must not be nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.stores[storeName]; exists {
+		return fmt.Errorf("cache store `%s` is already registered", storeName)
+	}
+	r.stores[storeName] = constructorFunc
+	return nil
+}
+
+// Unregister removes the store registered under the given name, if any.
+func (r *Registry) Unregister(storeName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.stores, storeName)
+}
+
+// Lookup returns the constructor registered under the given name.
+func (r *Registry) Lookup(storeName string) (StoreConstructorFunc, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	constructorFunc, ok := r.stores[storeName]
+	return constructorFunc, ok
+}
+
+// List returns the names of the registered stores in sorted order.
+func (r *Registry) List() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.stores))
+	for name := range r.stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,80 @@
+package cachey
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/codemaestro64/cachey/store"
+	"github.com/codemaestro64/cachey/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	assert.Empty(t, registry.List())
+
+	require.NoError(t, registry.Register("local", memory.NewMemoryStore))
+	assert.Error(t, registry.Register("local", memory.NewMemoryStore), "Names should not be registered twice")
+	assert.Error(t, registry.Register("nil", nil))
+
+	constructorFunc, ok := registry.Lookup("local")
+	assert.True(t, ok)
+	assert.IsType(t, &memory.MemoryStore{}, constructorFunc())
+
+	_, ok = registry.Lookup("missing")
+	assert.False(t, ok)
+
+	require.NoError(t, registry.Register("another", memory.NewMemoryStore))
+	assert.Equal(t, []string{"another", "local"}, registry.List())
+
+	registry.Unregister("local")
+	_, ok = registry.Lookup("local")
+	assert.False(t, ok)
+	assert.NoError(t, registry.Register("local", memory.NewMemoryStore), "Unregistered names should be reusable")
+}
+
+func TestRegistry_Concurrent(t *testing.T) {
+	registry := NewRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			assert.NoError(t, registry.Register(name, memory.NewMemoryStore))
+			registry.Lookup(name)
+			registry.List()
+			registry.Unregister(name)
+		}("store" + strconv.Itoa(i))
+	}
+	wg.Wait()
+
+	assert.Empty(t, registry.List())
+}
+
+func TestNewWithRegistry(t *testing.T) {
+	registry := NewRegistry()
+	require.NoError(t, registry.Register("bounded", func() store.Store {
+		return memory.NewMemoryStore()
+	}))
+
+	cache, err := NewWithRegistry(registry, "bounded", memory.WithMaxItems(10))
+	require.NoError(t, err)
+	defer cache.Close()
+
+	require.NoError(t, cache.Put("key", "value", ForeverDuration))
+	val, err := cache.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+
+	_, err = NewWithRegistry(registry, MemoryStore)
+	assert.Error(t, err, "Stores should only be looked up in the given registry")
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	for _, name := range []string{MemoryStore, RedisStore, FileStore, MemcachedStore, BoltStore, SQLStore} {
+		assert.Contains(t, DefaultRegistry.List(), name)
+	}
+}
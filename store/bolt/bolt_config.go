@@ -0,0 +1,89 @@
+package bolt
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+// Config configures a bolt store created with New. Zero fields take the
+// same defaults as NewBoltStore.
+type Config struct {
+	// Path is the location of the database file. Defaults to cachey.db in
+	// the user's cache directory.
+	Path string
+
+	// FileMode is the permission of the database file. Defaults to 0600.
+	FileMode os.FileMode
+
+	// Bucket holds the cache records. Defaults to "cachey".
+	Bucket string
+
+	// OpenTimeout is how long Init waits for the database file lock.
+	// Defaults to a second; a negative value waits indefinitely.
+	OpenTimeout time.Duration
+
+	// SweepInterval is how often expired records are removed. Defaults to
+	// a minute; a negative value disables the sweeper.
+	SweepInterval time.Duration
+
+	// CompactInterval is how often the database is compacted into a new
+	// file. Zero, the default, disables compaction.
+	CompactInterval time.Duration
+
+	// Codec serializes values. Defaults to store.GobCodec.
+	Codec store.Codec
+}
+
+// New returns a bolt store configured by cfg, or the field errors of an
+// invalid cfg. Pass the store to cachey.NewFromStore, which initializes it
+// and opens the database.
+func New(cfg Config) (*BoltStore, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	s := NewBoltStore().(*BoltStore)
+	if cfg.Path != "" {
+		s.config.path = cfg.Path
+	}
+	if cfg.FileMode != 0 {
+		s.config.fileMode = cfg.FileMode
+	}
+	if cfg.Bucket != "" {
+		s.config.bucket = cfg.Bucket
+	}
+	if cfg.OpenTimeout != 0 {
+		s.config.openTimeout = max(cfg.OpenTimeout, 0)
+	}
+	if cfg.SweepInterval != 0 {
+		s.config.sweepInterval = max(cfg.SweepInterval, 0)
+	}
+	if cfg.CompactInterval != 0 {
+		s.config.compactInterval = cfg.CompactInterval
+	}
+	if cfg.Codec != nil {
+		s.codec = cfg.Codec
+	}
+
+	return s, nil
+}
+
+func (cfg Config) validate() error {
+	var errs []error
+	invalid := func(field, reason string) {
+		errs = append(errs, &store.FieldError{Store: "bolt", Field: field, Reason: reason})
+	}
+
+	if cfg.FileMode&^os.ModePerm != 0 {
+		invalid("FileMode", "must only contain permission bits")
+	}
+
+	if cfg.CompactInterval < 0 {
+		invalid("CompactInterval", "must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
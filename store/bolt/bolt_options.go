@@ -0,0 +1,102 @@
+package bolt
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+// WithPath sets the location of the database file. Missing directories
+// are created when the store is initialized.
+func WithPath(path string) store.Option {
+	return func(s store.Store) error {
+		boltStore, ok := s.(*BoltStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for bolt options")
+		}
+
+		boltStore.config.path = path
+		return nil
+	}
+}
+
+func WithFileMode(mode os.FileMode) store.Option {
+	return func(s store.Store) error {
+		boltStore, ok := s.(*BoltStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for bolt options")
+		}
+
+		boltStore.config.fileMode = mode
+		return nil
+	}
+}
+
+// WithBucket sets the bucket cache records are kept in, allowing several
+// caches to share one database file.
+func WithBucket(bucket string) store.Option {
+	return func(s store.Store) error {
+		boltStore, ok := s.(*BoltStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for bolt options")
+		}
+
+		if bucket == "" {
+			return fmt.Errorf("bolt store: bucket name must not be empty")
+		}
+
+		boltStore.config.bucket = bucket
+		return nil
+	}
+}
+
+// WithOpenTimeout sets how long Init waits for the database file lock when
+// another process has the database open. Zero waits indefinitely.
+func WithOpenTimeout(timeout time.Duration) store.Option {
+	return func(s store.Store) error {
+		boltStore, ok := s.(*BoltStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for bolt options")
+		}
+
+		boltStore.config.openTimeout = timeout
+		return nil
+	}
+}
+
+// WithSweepInterval sets how often expired records are removed in the
+// background. Zero disables the sweeper; expired records are then ignored
+// on read but kept until FlushExpired is called.
+func WithSweepInterval(interval time.Duration) store.Option {
+	return func(s store.Store) error {
+		boltStore, ok := s.(*BoltStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for bolt options")
+		}
+
+		boltStore.config.sweepInterval = interval
+		return nil
+	}
+}
+
+// WithCompactInterval sets how often the database is compacted into a new
+// file in the background, which returns the space of deleted records to
+// the filesystem. Operations wait while the database is copied. Zero, the
+// default, disables compaction; Compact can still be called directly.
+func WithCompactInterval(interval time.Duration) store.Option {
+	return func(s store.Store) error {
+		boltStore, ok := s.(*BoltStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for bolt options")
+		}
+
+		if interval < 0 {
+			return fmt.Errorf("bolt store: compact interval must not be negative")
+		}
+
+		boltStore.config.compactInterval = interval
+		return nil
+	}
+}
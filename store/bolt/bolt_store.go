@@ -0,0 +1,698 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+	bbolt "go.etcd.io/bbolt"
+)
+
+type config struct {
+	path            string
+	fileMode        os.FileMode
+	bucket          string
+	openTimeout     time.Duration
+	sweepInterval   time.Duration
+	compactInterval time.Duration
+}
+
+// BoltStore keeps cache entries in a local bbolt database file. Each
+// record holds the entry's expiry time followed by the encoded value, so
+// entries keep their TTLs across restarts.
+//
+// bbolt reuses the pages of deleted records but never shrinks its file,
+// so the store can periodically compact the database into a new file.
+type BoltStore struct {
+	config *config
+	mu     sync.RWMutex // Held for reading by every transaction, and for writing while Compact replaces db.
+	db     *bbolt.DB
+	codec  store.Codec
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewBoltStore() store.Store {
+	defaultConfig := config{
+		path:          defaultPath(),
+		fileMode:      0o600,
+		bucket:        "cachey",
+		openTimeout:   time.Second,
+		sweepInterval: time.Minute,
+	}
+
+	return &BoltStore{
+		config: &defaultConfig,
+		codec:  store.GobCodec{},
+	}
+}
+
+// defaultPath returns cachey.db in the user's cache directory, falling
+// back to the temporary directory if there is none.
+func defaultPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, "cachey.db")
+}
+
+func (s *BoltStore) Init() error {
+	if s.config == nil {
+		return errors.New("bolt store: configuration is missing")
+	}
+
+	if s.codec == nil {
+		return errors.New("bolt store: a codec is required")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.config.path), 0o700); err != nil {
+		return fmt.Errorf("bolt store: error creating database directory: %w", err)
+	}
+
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+
+	s.db = db
+
+	if s.config.sweepInterval > 0 || s.config.compactInterval > 0 {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.sweep()
+	}
+
+	return nil
+}
+
+// open opens the database file and creates the bucket if it is missing.
+func (s *BoltStore) open() (*bbolt.DB, error) {
+	db, err := bbolt.Open(s.config.path, s.config.fileMode, &bbolt.Options{Timeout: s.config.openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("bolt store: error opening database: %w", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(s.config.bucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("bolt store: error creating bucket: %w", err)
+	}
+
+	return db, nil
+}
+
+// Close stops the background sweeper and closes the database, releasing
+// its file lock.
+func (s *BoltStore) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+			<-s.done
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.db != nil {
+			err = s.db.Close()
+		}
+	})
+
+	return err
+}
+
+// Ping checks that the database is open and its bucket can be read.
+func (s *BoltStore) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := s.view(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte(s.config.bucket)) == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("bolt store: error reading database: %w", err)
+	}
+
+	return nil
+}
+
+func (s *BoltStore) SetCodec(codec store.Codec) {
+	s.codec = codec
+}
+
+func (s *BoltStore) Codec() store.Codec {
+	return s.codec
+}
+
+func (s *BoltStore) Has(key string) (bool, error) {
+	return s.HasCtx(context.Background(), key)
+}
+
+func (s *BoltStore) HasCtx(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	var found bool
+	err := s.view(func(tx *bbolt.Tx) error {
+		_, found = s.lookup(tx, key)
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("bolt store: error reading database: %w", err)
+	}
+
+	return found, nil
+}
+
+func (s *BoltStore) Get(key string) (any, error) {
+	return s.GetCtx(context.Background(), key)
+}
+
+func (s *BoltStore) GetCtx(ctx context.Context, key string) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var record []byte
+	err := s.view(func(tx *bbolt.Tx) error {
+		if r, ok := s.lookup(tx, key); ok {
+			// records are only valid for the life of the transaction
+			record = bytes.Clone(r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bolt store: error reading database: %w", err)
+	}
+
+	if record == nil {
+		return nil, nil
+	}
+
+	return s.decode(record)
+}
+
+func (s *BoltStore) Put(key string, data any, duration time.Duration) error {
+	return s.PutCtx(context.Background(), key, data, duration)
+}
+
+func (s *BoltStore) PutCtx(ctx context.Context, key string, data any, duration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	record, err := s.record(data, duration)
+	if err != nil {
+		return err
+	}
+
+	err = s.update(func(tx *bbolt.Tx) error {
+		return s.bucket(tx).Put([]byte(key), record)
+	})
+	if err != nil {
+		return fmt.Errorf("bolt store: error saving item to the store: %w", err)
+	}
+
+	return nil
+}
+
+func (s *BoltStore) PutIfAbsent(ctx context.Context, key string, data any, duration time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	record, err := s.record(data, duration)
+	if err != nil {
+		return false, err
+	}
+
+	var added bool
+	err = s.update(func(tx *bbolt.Tx) error {
+		if _, ok := s.lookup(tx, key); ok {
+			return nil
+		}
+
+		added = true
+		return s.bucket(tx).Put([]byte(key), record)
+	})
+	if err != nil {
+		return false, fmt.Errorf("bolt store: error adding item to the store: %w", err)
+	}
+
+	return added, nil
+}
+
+// Increment adds by to the counter stored under key in a single
+// transaction. Counters are kept as decimal text rather than through the
+// codec.
+func (s *BoltStore) Increment(ctx context.Context, key string, by int64, duration time.Duration) (int64, error) {
+	var value int64
+	err := s.updateCounter(ctx, key, duration, func(current []byte) ([]byte, error) {
+		n, err := strconv.ParseInt(string(current), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("counter is not an integer: %w", err)
+		}
+
+		value = n + by
+		return strconv.AppendInt(nil, value, 10), nil
+	})
+
+	return value, err
+}
+
+func (s *BoltStore) IncrementFloat(ctx context.Context, key string, by float64, duration time.Duration) (float64, error) {
+	var value float64
+	err := s.updateCounter(ctx, key, duration, func(current []byte) ([]byte, error) {
+		n, err := strconv.ParseFloat(string(current), 64)
+		if err != nil {
+			return nil, fmt.Errorf("counter is not a number: %w", err)
+		}
+
+		value = n + by
+		return strconv.AppendFloat(nil, value, 'g', -1, 64), nil
+	})
+
+	return value, err
+}
+
+// updateCounter replaces the counter stored under key with the result of
+// fn. A missing or expired counter is passed to fn as zero and stored with
+// duration; otherwise the expiry is kept.
+func (s *BoltStore) updateCounter(ctx context.Context, key string, duration time.Duration, fn func(current []byte) ([]byte, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := s.update(func(tx *bbolt.Tx) error {
+		expiresAt, current := expiry(duration), []byte("0")
+		if record, ok := s.lookup(tx, key); ok {
+			if !isCounter(record) {
+				return errors.New("value is not a counter")
+			}
+			expiresAt, current = expiresAtOf(record), record[headerSize:]
+		}
+
+		value, err := fn(current)
+		if err != nil {
+			return err
+		}
+
+		return s.bucket(tx).Put([]byte(key), newRecord(expiresAt, true, value))
+	})
+	if err != nil {
+		return fmt.Errorf("bolt store: error updating counter `%s`: %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *BoltStore) GetMany(ctx context.Context, keys []string) (map[string]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	records := make(map[string][]byte, len(keys))
+	err := s.view(func(tx *bbolt.Tx) error {
+		for _, key := range keys {
+			if r, ok := s.lookup(tx, key); ok {
+				records[key] = bytes.Clone(r)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bolt store: error reading database: %w", err)
+	}
+
+	items := make(map[string]any, len(records))
+	for key, record := range records {
+		data, err := s.decode(record)
+		if err != nil {
+			return nil, err
+		}
+		items[key] = data
+	}
+
+	return items, nil
+}
+
+func (s *BoltStore) PutMany(ctx context.Context, items map[string]any, duration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	records := make(map[string][]byte, len(items))
+	for key, data := range items {
+		record, err := s.record(data, duration)
+		if err != nil {
+			return err
+		}
+		records[key] = record
+	}
+
+	err := s.update(func(tx *bbolt.Tx) error {
+		bucket := s.bucket(tx)
+		for key, record := range records {
+			if err := bucket.Put([]byte(key), record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("bolt store: error saving items to the store: %w", err)
+	}
+
+	return nil
+}
+
+func (s *BoltStore) DeleteMany(ctx context.Context, keys []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := s.update(func(tx *bbolt.Tx) error {
+		bucket := s.bucket(tx)
+		for _, key := range keys {
+			if err := bucket.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("bolt store: error deleting keys: %w", err)
+	}
+
+	return nil
+}
+
+func (s *BoltStore) Delete(key string) error {
+	return s.DeleteCtx(context.Background(), key)
+}
+
+func (s *BoltStore) DeleteCtx(ctx context.Context, key string) error {
+	return s.DeleteMany(ctx, []string{key})
+}
+
+func (s *BoltStore) Flush() error {
+	return s.FlushCtx(context.Background())
+}
+
+func (s *BoltStore) FlushCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := s.update(func(tx *bbolt.Tx) error {
+		name := []byte(s.config.bucket)
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+
+		_, err := tx.CreateBucket(name)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("bolt store: error flushing database: %w", err)
+	}
+
+	return nil
+}
+
+// FlushPrefix removes every key starting with prefix. Keys are kept in
+// sorted order, so only the matching range is visited.
+func (s *BoltStore) FlushPrefix(ctx context.Context, prefix string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := s.update(func(tx *bbolt.Tx) error {
+		var keys [][]byte
+		c := s.bucket(tx).Cursor()
+		for k, _ := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = c.Next() {
+			keys = append(keys, bytes.Clone(k))
+		}
+		return deleteKeys(s.bucket(tx), keys)
+	})
+	if err != nil {
+		return fmt.Errorf("bolt store: error flushing prefix: %w", err)
+	}
+
+	return nil
+}
+
+// FlushExpired removes every expired record from the database.
+func (s *BoltStore) FlushExpired() error {
+	now := time.Now().UnixNano()
+
+	err := s.update(func(tx *bbolt.Tx) error {
+		var keys [][]byte
+		c := s.bucket(tx).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if expired(v, now) {
+				keys = append(keys, bytes.Clone(k))
+			}
+		}
+		return deleteKeys(s.bucket(tx), keys)
+	})
+	if err != nil {
+		return fmt.Errorf("bolt store: error removing expired records: %w", err)
+	}
+
+	return nil
+}
+
+// compactTxSize bounds the size of the transactions Compact writes the
+// new database with.
+const compactTxSize = 64 << 20
+
+// Compact copies the live records into a new database file and swaps it
+// in, returning the space of deleted and expired records to the
+// filesystem. Other operations wait until the swap is done.
+func (s *BoltStore) Compact() error {
+	if err := s.FlushExpired(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp := s.config.path + ".compact"
+	if err := os.Remove(tmp); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("bolt store: error compacting database: %w", err)
+	}
+
+	dst, err := bbolt.Open(tmp, s.config.fileMode, nil)
+	if err != nil {
+		return fmt.Errorf("bolt store: error compacting database: %w", err)
+	}
+
+	err = bbolt.Compact(dst, s.db, compactTxSize)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("bolt store: error compacting database: %w", err)
+	}
+
+	// the file cannot be replaced while it is open on every platform, so
+	// the database is closed and reopened around the swap
+	if err := s.db.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("bolt store: error compacting database: %w", err)
+	}
+
+	renameErr := os.Rename(tmp, s.config.path)
+	if renameErr != nil {
+		os.Remove(tmp)
+	}
+
+	db, err := s.open()
+	if err != nil {
+		// db stays closed, so operations fail instead of using a stale handle
+		return err
+	}
+	s.db = db
+
+	if renameErr != nil {
+		return fmt.Errorf("bolt store: error compacting database: %w", renameErr)
+	}
+
+	return nil
+}
+
+// sweep periodically removes expired records and compacts the database
+// until the store is closed.
+func (s *BoltStore) sweep() {
+	defer close(s.done)
+
+	var sweeps, compactions <-chan time.Time
+	if s.config.sweepInterval > 0 {
+		ticker := time.NewTicker(s.config.sweepInterval)
+		defer ticker.Stop()
+		sweeps = ticker.C
+	}
+	if s.config.compactInterval > 0 {
+		ticker := time.NewTicker(s.config.compactInterval)
+		defer ticker.Stop()
+		compactions = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-sweeps:
+			s.FlushExpired()
+		case <-compactions:
+			s.Compact()
+		}
+	}
+}
+
+// deleteKeys removes keys from bucket. Deleting through a cursor while
+// iterating can skip records, so keys are collected first.
+func deleteKeys(bucket *bbolt.Bucket, keys [][]byte) error {
+	for _, key := range keys {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// view runs fn in a read-only transaction.
+func (s *BoltStore) view(fn func(tx *bbolt.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.db.View(fn)
+}
+
+// update runs fn in a read-write transaction.
+func (s *BoltStore) update(fn func(tx *bbolt.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.db.Update(fn)
+}
+
+func (s *BoltStore) bucket(tx *bbolt.Tx) *bbolt.Bucket {
+	return tx.Bucket([]byte(s.config.bucket))
+}
+
+// lookup returns the live record stored under key. Expired records are
+// left for the sweeper, as read transactions cannot delete.
+func (s *BoltStore) lookup(tx *bbolt.Tx, key string) ([]byte, bool) {
+	record := s.bucket(tx).Get([]byte(key))
+	if record == nil || expired(record, time.Now().UnixNano()) {
+		return nil, false
+	}
+
+	return record, true
+}
+
+// Records start with the expiry time in unix nanoseconds; zero means the
+// record never expires. Expiry times are never negative, so the top bit
+// of the header marks counters, whose values are decimal text.
+const (
+	headerSize = 8
+	counterBit = 1 << 63
+)
+
+// record encodes data into a database record expiring after duration.
+func (s *BoltStore) record(data any, duration time.Duration) ([]byte, error) {
+	data, err := store.Encode(s.codec, data)
+	if err != nil {
+		return nil, fmt.Errorf("bolt store: %w", err)
+	}
+
+	return newRecord(expiry(duration), false, data.([]byte)), nil
+}
+
+func newRecord(expiresAt int64, counter bool, value []byte) []byte {
+	header := uint64(expiresAt)
+	if counter {
+		header |= counterBit
+	}
+
+	record := make([]byte, headerSize, headerSize+len(value))
+	binary.BigEndian.PutUint64(record, header)
+	return append(record, value...)
+}
+
+// decode returns the value held by a live record.
+func (s *BoltStore) decode(record []byte) (any, error) {
+	value := record[headerSize:]
+	if isCounter(record) {
+		if n, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+			return n, nil
+		}
+
+		n, err := strconv.ParseFloat(string(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bolt store: error reading counter: %w", err)
+		}
+		return n, nil
+	}
+
+	data, err := store.Decode(s.codec, value)
+	if err != nil {
+		return nil, fmt.Errorf("bolt store: %w", err)
+	}
+
+	return data, nil
+}
+
+// expiry returns the expiry time in unix nanoseconds for a record stored
+// for duration, or zero if it never expires.
+func expiry(duration time.Duration) int64 {
+	if duration <= 0 {
+		return 0
+	}
+
+	return time.Now().Add(duration).UnixNano()
+}
+
+func expiresAtOf(record []byte) int64 {
+	return int64(binary.BigEndian.Uint64(record[:headerSize]) &^ counterBit)
+}
+
+func isCounter(record []byte) bool {
+	return binary.BigEndian.Uint64(record[:headerSize])&counterBit != 0
+}
+
+// expired reports whether record has expired at now. Records too short to
+// hold a header are treated as expired so the sweeper removes them.
+func expired(record []byte, now int64) bool {
+	if len(record) < headerSize {
+		return true
+	}
+
+	expiresAt := expiresAtOf(record)
+	return expiresAt != 0 && now >= expiresAt
+}
@@ -0,0 +1,344 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bbolt "go.etcd.io/bbolt"
+)
+
+func TestBoltStore(t *testing.T) {
+	store := NewBoltStore().(*BoltStore)
+	store.config.path = filepath.Join(t.TempDir(), "cache.db")
+	store.config.sweepInterval = 0
+
+	err := store.Init()
+	require.NoError(t, err, "Failed to initialize bolt store")
+	defer store.Close()
+
+	t.Run("Put and Get", func(t *testing.T) {
+		err := store.Put("key", "value", time.Minute)
+		assert.NoError(t, err)
+
+		val, err := store.Get("key")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", val)
+	})
+
+	t.Run("Get - Key Does Not Exist", func(t *testing.T) {
+		val, err := store.Get("missing")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		err := store.Put("expiring", "value", 50*time.Millisecond)
+		assert.NoError(t, err)
+
+		time.Sleep(100 * time.Millisecond)
+
+		has, err := store.Has("expiring")
+		assert.NoError(t, err)
+		assert.False(t, has)
+
+		err = store.FlushExpired()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, store.count("expiring"), "Expired record should be removed")
+	})
+
+	t.Run("PutIfAbsent", func(t *testing.T) {
+		added, err := store.PutIfAbsent(context.Background(), "key", "other", time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, added)
+
+		added, err = store.PutIfAbsent(context.Background(), "added", "value", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, added)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		items := map[string]any{"batch1": "value1", "batch2": "value2"}
+
+		err := store.PutMany(context.Background(), items, time.Minute)
+		assert.NoError(t, err)
+
+		vals, err := store.GetMany(context.Background(), []string{"batch1", "batch2", "missing"})
+		assert.NoError(t, err)
+		assert.Equal(t, items, vals)
+
+		err = store.DeleteMany(context.Background(), []string{"batch1", "batch2"})
+		assert.NoError(t, err)
+
+		vals, err = store.GetMany(context.Background(), []string{"batch1", "batch2"})
+		assert.NoError(t, err)
+		assert.Empty(t, vals)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		err := store.Delete("key")
+		assert.NoError(t, err)
+
+		has, err := store.Has("key")
+		assert.NoError(t, err)
+		assert.False(t, has)
+
+		err = store.Delete("key")
+		assert.NoError(t, err, "Deleting a missing key should not fail")
+	})
+
+	t.Run("FlushPrefix", func(t *testing.T) {
+		_ = store.Put("svc-a:key1", "value1", time.Minute)
+		_ = store.Put("svc-a:key2", "value2", time.Minute)
+		_ = store.Put("svc-b:key1", "value1", time.Minute)
+
+		err := store.FlushPrefix(context.Background(), "svc-a:")
+		assert.NoError(t, err)
+
+		has, _ := store.Has("svc-a:key1")
+		assert.False(t, has)
+
+		has, _ = store.Has("svc-a:key2")
+		assert.False(t, has)
+
+		has, _ = store.Has("svc-b:key1")
+		assert.True(t, has)
+	})
+
+	t.Run("Flush", func(t *testing.T) {
+		_ = store.Put("key1", "value1", time.Minute)
+		_ = store.Put("key2", "value2", time.Minute)
+
+		err := store.Flush()
+		assert.NoError(t, err)
+
+		has, _ := store.Has("key1")
+		assert.False(t, has)
+		assert.Equal(t, 0, store.count(""))
+	})
+}
+
+func TestBoltStore_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	first := NewBoltStore()
+	require.NoError(t, WithPath(path)(first))
+	require.NoError(t, first.Init())
+	require.NoError(t, first.Put("key", "value", time.Minute))
+	require.NoError(t, first.Put("expiring", "value", 50*time.Millisecond))
+	require.NoError(t, first.(*BoltStore).Close())
+
+	time.Sleep(100 * time.Millisecond)
+
+	second := NewBoltStore()
+	require.NoError(t, WithPath(path)(second))
+	require.NoError(t, second.Init())
+	defer second.(*BoltStore).Close()
+
+	val, err := second.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+
+	has, err := second.Has("expiring")
+	assert.NoError(t, err)
+	assert.False(t, has, "TTLs should be kept across restarts")
+}
+
+func TestBoltStore_Sweeper(t *testing.T) {
+	store := NewBoltStore().(*BoltStore)
+	require.NoError(t, WithPath(filepath.Join(t.TempDir(), "cache.db"))(store))
+	require.NoError(t, WithSweepInterval(50*time.Millisecond)(store))
+	require.NoError(t, store.Init())
+	defer store.Close()
+
+	require.NoError(t, store.Put("expiring", "value", 10*time.Millisecond))
+	require.NoError(t, store.Put("forever", "value", -1))
+
+	time.Sleep(200 * time.Millisecond)
+
+	assert.Equal(t, 0, store.count("expiring"), "Expired record should be swept")
+	assert.Equal(t, 1, store.count("forever"), "Live record should not be swept")
+}
+
+func TestBoltStore_Ping(t *testing.T) {
+	store := NewBoltStore().(*BoltStore)
+	require.NoError(t, WithPath(filepath.Join(t.TempDir(), "cache.db"))(store))
+	require.NoError(t, store.Init())
+
+	assert.NoError(t, store.Ping(context.Background()))
+
+	require.NoError(t, store.Close())
+	assert.Error(t, store.Ping(context.Background()), "A closed database should not be healthy")
+}
+
+func TestBoltStore_Increment(t *testing.T) {
+	store := NewBoltStore().(*BoltStore)
+	require.NoError(t, WithPath(filepath.Join(t.TempDir(), "cache.db"))(store))
+	require.NoError(t, store.Init())
+	defer store.Close()
+
+	ctx := context.Background()
+
+	val, err := store.Increment(ctx, "counter", 2, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), val)
+
+	var before, after int64
+	store.view(func(tx *bbolt.Tx) error {
+		before = expiresAtOf(store.bucket(tx).Get([]byte("counter")))
+		return nil
+	})
+
+	val, err = store.Increment(ctx, "counter", -5, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-3), val)
+
+	store.view(func(tx *bbolt.Tx) error {
+		after = expiresAtOf(store.bucket(tx).Get([]byte("counter")))
+		return nil
+	})
+	assert.Equal(t, before, after, "Incrementing should keep the expiry")
+
+	got, err := store.Get("counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-3), got)
+
+	floatVal, err := store.IncrementFloat(ctx, "float", 0.5, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, floatVal)
+
+	floatVal, err = store.IncrementFloat(ctx, "float", 0.25, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.75, floatVal)
+
+	_, err = store.Increment(ctx, "float", 1, time.Minute)
+	assert.Error(t, err, "A floating point counter is not an integer")
+
+	require.NoError(t, store.Put("text", "value", time.Minute))
+	_, err = store.Increment(ctx, "text", 1, time.Minute)
+	assert.Error(t, err, "Values stored through the codec are not counters")
+
+	require.NoError(t, store.Put("expiring", "value", 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	val, err = store.Increment(ctx, "expiring", 1, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), val, "An expired record should start again from zero")
+}
+
+func TestBoltStore_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	store := NewBoltStore().(*BoltStore)
+	require.NoError(t, WithPath(path)(store))
+	require.NoError(t, store.Init())
+	defer store.Close()
+
+	items := make(map[string]any, 2000)
+	for i := range 2000 {
+		items[fmt.Sprintf("key-%d", i)] = strings.Repeat("x", 1024)
+	}
+	ctx := context.Background()
+	require.NoError(t, store.PutMany(ctx, items, time.Minute))
+	require.NoError(t, store.FlushPrefix(ctx, "key-"))
+	require.NoError(t, store.Put("kept", "value", time.Minute))
+
+	before, err := os.Stat(path)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Compact())
+
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Less(t, after.Size(), before.Size(), "Compaction should shrink the file")
+
+	_, err = os.Stat(path + ".compact")
+	assert.True(t, os.IsNotExist(err), "The temporary database should be gone")
+
+	val, err := store.Get("kept")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+
+	require.NoError(t, store.Put("after", "value", time.Minute))
+	val, err = store.Get("after")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+}
+
+func TestBoltStore_CompactInterval(t *testing.T) {
+	store := NewBoltStore().(*BoltStore)
+	require.NoError(t, WithPath(filepath.Join(t.TempDir(), "cache.db"))(store))
+	require.NoError(t, WithSweepInterval(0)(store))
+	require.NoError(t, WithCompactInterval(10*time.Millisecond)(store))
+	assert.Error(t, WithCompactInterval(-1)(store))
+	require.NoError(t, store.Init())
+	defer store.Close()
+
+	// operations keep working while the database is swapped underneath
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for i := 0; time.Now().Before(deadline); i++ {
+		key := fmt.Sprintf("key-%d", i)
+		require.NoError(t, store.Put(key, i, time.Minute))
+
+		val, err := store.Get(key)
+		require.NoError(t, err)
+		require.Equal(t, i, val)
+	}
+}
+
+func TestNewBoltStore_DefaultPath(t *testing.T) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		t.Skip("no user cache directory")
+	}
+
+	store := NewBoltStore().(*BoltStore)
+	assert.Equal(t, filepath.Join(cacheDir, "cachey.db"), store.config.path)
+}
+
+// count returns the number of records, live or expired, whose key starts
+// with prefix.
+func (s *BoltStore) count(prefix string) int {
+	var n int
+	s.view(func(tx *bbolt.Tx) error {
+		c := s.bucket(tx).Cursor()
+		for k, _ := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = c.Next() {
+			n++
+		}
+		return nil
+	})
+	return n
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{FileMode: os.ModeSymlink, CompactInterval: -1})
+	var fields []string
+	for _, fieldError := range store.FieldErrors(err) {
+		fields = append(fields, fieldError.Field)
+	}
+	assert.Equal(t, []string{"FileMode", "CompactInterval"}, fields)
+
+	boltStore, err := New(Config{
+		Path:          filepath.Join(t.TempDir(), "cache.db"),
+		Bucket:        "sessions",
+		SweepInterval: -1,
+	})
+	require.NoError(t, err)
+	require.NoError(t, boltStore.Init())
+	defer boltStore.Close()
+
+	assert.Equal(t, time.Duration(0), boltStore.config.sweepInterval)
+	assert.Equal(t, time.Second, boltStore.config.openTimeout, "Zero fields should take the defaults")
+
+	require.NoError(t, boltStore.Put("key", "value", time.Minute))
+	val, err := boltStore.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+}
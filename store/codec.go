@@ -0,0 +1,153 @@
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes values to bytes before they are written to a store and
+// decodes them again when they are read back.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// CodecStore is implemented by stores that can serialize values through a Codec.
+type CodecStore interface {
+	SetCodec(codec Codec)
+	Codec() Codec // Returns the configured codec, or nil.
+}
+
+// WithCodec configures the codec used by the store to serialize values.
+func WithCodec(codec Codec) Option {
+	return func(s Store) error {
+		codecStore, ok := s.(CodecStore)
+		if !ok {
+			return fmt.Errorf("store %T does not support codecs", s)
+		}
+
+		codecStore.SetCodec(codec)
+		return nil
+	}
+}
+
+// Encode marshals data with codec. A nil codec returns data unchanged.
+func Encode(codec Codec, data any) (any, error) {
+	if codec == nil {
+		return data, nil
+	}
+
+	encoded, err := codec.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding value: %w", err)
+	}
+	return encoded, nil
+}
+
+// Decode unmarshals data with codec into a generic value.
+func Decode(codec Codec, data []byte) (any, error) {
+	var value any
+	if err := codec.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("error decoding value: %w", err)
+	}
+	return value, nil
+}
+
+// JSONCodec serializes values as JSON. Structs read back through a
+// generic value are decoded as map[string]any.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// GobCodec serializes values with encoding/gob. Values are encoded as
+// interfaces, so their concrete types survive a round-trip as long as
+// they are registered with gob.Register.
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	var value any
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return err
+	}
+	return assign(v, value)
+}
+
+// MsgpackCodec serializes values with the compact MessagePack binary format.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// RawCodec stores []byte and string values verbatim. Values are read
+// back as []byte unless decoded into a *string.
+type RawCodec struct{}
+
+func (RawCodec) Marshal(v any) ([]byte, error) {
+	switch value := v.(type) {
+	case []byte:
+		return value, nil
+	case string:
+		return []byte(value), nil
+	default:
+		return nil, fmt.Errorf("raw codec: cannot marshal %T", v)
+	}
+}
+
+func (RawCodec) Unmarshal(data []byte, v any) error {
+	switch target := v.(type) {
+	case *[]byte:
+		*target = append([]byte(nil), data...)
+	case *string:
+		*target = string(data)
+	case *any:
+		*target = append([]byte(nil), data...)
+	default:
+		return fmt.Errorf("raw codec: cannot unmarshal into %T", v)
+	}
+	return nil
+}
+
+// assign stores value in the variable pointed to by target.
+func assign(target any, value any) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return fmt.Errorf("cannot decode into non-pointer %T", target)
+	}
+
+	elem := ptr.Elem()
+	if value == nil {
+		elem.SetZero()
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	if !v.Type().AssignableTo(elem.Type()) {
+		return fmt.Errorf("cannot decode %T into %s", value, elem.Type())
+	}
+	elem.Set(v)
+	return nil
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type codecItem struct {
+	Name  string
+	Count int
+}
+
+func init() {
+	gob.Register(codecItem{})
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	codecs := map[string]Codec{
+		"json":    JSONCodec{},
+		"gob":     GobCodec{},
+		"msgpack": MsgpackCodec{},
+	}
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			item := codecItem{Name: "widget", Count: 3}
+
+			data, err := codec.Marshal(item)
+			assert.NoError(t, err)
+
+			var decoded codecItem
+			err = codec.Unmarshal(data, &decoded)
+			assert.NoError(t, err)
+			assert.Equal(t, item, decoded)
+
+			// strings decode back into generic values unchanged
+			data, err = codec.Marshal("value")
+			assert.NoError(t, err)
+
+			value, err := Decode(codec, data)
+			assert.NoError(t, err)
+			assert.Equal(t, "value", value)
+		})
+	}
+}
+
+func TestGobCodec_PreservesConcreteType(t *testing.T) {
+	item := codecItem{Name: "widget", Count: 3}
+
+	data, err := GobCodec{}.Marshal(item)
+	assert.NoError(t, err)
+
+	value, err := Decode(GobCodec{}, data)
+	assert.NoError(t, err)
+	assert.Equal(t, item, value)
+}
+
+func TestRawCodec(t *testing.T) {
+	data, err := RawCodec{}.Marshal("payload")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("payload"), data)
+
+	value, err := Decode(RawCodec{}, data)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("payload"), value)
+
+	var s string
+	err = RawCodec{}.Unmarshal(data, &s)
+	assert.NoError(t, err)
+	assert.Equal(t, "payload", s)
+
+	_, err = RawCodec{}.Marshal(42)
+	assert.Error(t, err)
+}
+
+func TestWithCodec_UnsupportedStore(t *testing.T) {
+	err := WithCodec(JSONCodec{})(contextStore{})
+	assert.Error(t, err)
+}
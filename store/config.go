@@ -0,0 +1,38 @@
+package store
+
+import "fmt"
+
+// FieldError reports an invalid field of a store's Config. Constructors
+// taking a Config report every invalid field at once, joined with
+// errors.Join; use FieldErrors to list them.
+type FieldError struct {
+	Store  string // Name of the store, such as "redis".
+	Field  string // Name of the Config field.
+	Reason string // Why the value is invalid.
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s store: invalid %s: %s", e.Store, e.Field, e.Reason)
+}
+
+// FieldErrors returns the field errors in err's tree.
+func FieldErrors(err error) []*FieldError {
+	var fieldErrors []*FieldError
+
+	var walk func(err error)
+	walk = func(err error) {
+		switch err := err.(type) {
+		case *FieldError:
+			fieldErrors = append(fieldErrors, err)
+		case interface{ Unwrap() []error }:
+			for _, err := range err.Unwrap() {
+				walk(err)
+			}
+		case interface{ Unwrap() error }:
+			walk(err.Unwrap())
+		}
+	}
+	walk(err)
+
+	return fieldErrors
+}
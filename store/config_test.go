@@ -0,0 +1,26 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldErrors(t *testing.T) {
+	first := &FieldError{Store: "redis", Field: "DB", Reason: "must not be negative"}
+	second := &FieldError{Store: "redis", Field: "PoolSize", Reason: "must not be negative"}
+
+	assert.Equal(t, "redis store: invalid DB: must not be negative", first.Error())
+
+	err := fmt.Errorf("configuring cache: %w", errors.Join(first, errors.New("other"), second))
+	assert.Equal(t, []*FieldError{first, second}, FieldErrors(err))
+
+	var fieldError *FieldError
+	assert.ErrorAs(t, err, &fieldError)
+	assert.Equal(t, "DB", fieldError.Field)
+
+	assert.Empty(t, FieldErrors(errors.New("other")))
+	assert.Empty(t, FieldErrors(nil))
+}
@@ -0,0 +1,77 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+// Config configures a file store created with New. Zero fields take the
+// same defaults as NewFileStore.
+type Config struct {
+	// Directory holds the cache files. Defaults to a cachey directory in
+	// the user's cache directory.
+	Directory string
+
+	// FileMode is the permission of cache files. Defaults to 0600.
+	FileMode os.FileMode
+
+	// ShardDepth is how many levels of subdirectories files are spread
+	// across, as with WithShardDepth. Defaults to 2; a negative value keeps
+	// every file in Directory.
+	ShardDepth int
+
+	// SweepInterval is how often expired files are removed. Defaults to a
+	// minute; a negative value disables the sweeper.
+	SweepInterval time.Duration
+
+	// Codec serializes values. Defaults to store.GobCodec.
+	Codec store.Codec
+}
+
+// New returns a file store configured by cfg, or the field errors of an
+// invalid cfg. Pass the store to cachey.NewFromStore, which initializes it.
+func New(cfg Config) (*FileStore, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	s := NewFileStore().(*FileStore)
+	if cfg.Directory != "" {
+		s.config.directory = cfg.Directory
+	}
+	if cfg.FileMode != 0 {
+		s.config.fileMode = cfg.FileMode
+	}
+	if cfg.ShardDepth != 0 {
+		s.config.shardDepth = max(cfg.ShardDepth, 0)
+	}
+	if cfg.SweepInterval != 0 {
+		s.config.sweepInterval = max(cfg.SweepInterval, 0)
+	}
+	if cfg.Codec != nil {
+		s.codec = cfg.Codec
+	}
+
+	return s, nil
+}
+
+func (cfg Config) validate() error {
+	var errs []error
+	invalid := func(field, reason string) {
+		errs = append(errs, &store.FieldError{Store: "file", Field: field, Reason: reason})
+	}
+
+	if cfg.FileMode&^os.ModePerm != 0 {
+		invalid("FileMode", "must only contain permission bits")
+	}
+
+	if cfg.ShardDepth > maxShardDepth {
+		invalid("ShardDepth", fmt.Sprintf("must not exceed %d", maxShardDepth))
+	}
+
+	return errors.Join(errs...)
+}
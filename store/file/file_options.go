@@ -0,0 +1,68 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+func WithDirectory(directory string) store.Option {
+	return func(s store.Store) error {
+		fileStore, ok := s.(*FileStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for file options")
+		}
+
+		fileStore.config.directory = directory
+		return nil
+	}
+}
+
+func WithFileMode(mode os.FileMode) store.Option {
+	return func(s store.Store) error {
+		fileStore, ok := s.(*FileStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for file options")
+		}
+
+		fileStore.config.fileMode = mode
+		return nil
+	}
+}
+
+// WithShardDepth sets how many levels of subdirectories cache files are
+// spread across. Each level is named after two hex characters of the key's
+// hash, so a depth of 2 gives up to 65536 directories. Zero keeps every
+// file in the base directory.
+func WithShardDepth(depth int) store.Option {
+	return func(s store.Store) error {
+		fileStore, ok := s.(*FileStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for file options")
+		}
+
+		if depth < 0 || depth > maxShardDepth {
+			return fmt.Errorf("file store: shard depth must be between 0 and %d", maxShardDepth)
+		}
+
+		fileStore.config.shardDepth = depth
+		return nil
+	}
+}
+
+// WithSweepInterval sets how often expired files are removed in the
+// background. Zero disables the sweeper; expired files are then only
+// removed when they are read.
+func WithSweepInterval(interval time.Duration) store.Option {
+	return func(s store.Store) error {
+		fileStore, ok := s.(*FileStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for file options")
+		}
+
+		fileStore.config.sweepInterval = interval
+		return nil
+	}
+}
@@ -0,0 +1,835 @@
+package file
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+// maxShardDepth is the deepest supported subdirectory layout.
+const maxShardDepth = 4
+
+// tempPattern names the temporary files used for atomic writes. The
+// leading dot keeps them out of the way of the sweeper and FlushPrefix.
+const tempPattern = ".tmp-*"
+
+// staleTempAge is how old a temporary file must be before Flush assumes
+// the write it belonged to was abandoned.
+const staleTempAge = time.Hour
+
+// Counter updates hold a lock file next to the counter's cache file, so
+// that processes sharing the directory take turns. A lock file older than
+// staleLockAge is assumed to belong to a process that died holding it.
+const (
+	lockSuffix     = ".lock"
+	staleLockAge   = 10 * time.Second
+	lockRetryDelay = 5 * time.Millisecond
+)
+
+type config struct {
+	directory     string
+	fileMode      os.FileMode
+	shardDepth    int
+	sweepInterval time.Duration
+}
+
+// FileStore keeps each cache entry in its own file. A file holds the
+// entry's expiry time and key followed by the encoded value, and is
+// replaced atomically on every write.
+type FileStore struct {
+	config *config
+	codec  store.Codec
+
+	mu        sync.Mutex // Serializes writes and removals, so a file just written is never removed as expired.
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewFileStore() store.Store {
+	defaultConfig := config{
+		directory:     defaultDirectory(),
+		fileMode:      0o600,
+		shardDepth:    2,
+		sweepInterval: time.Minute,
+	}
+
+	return &FileStore{
+		config: &defaultConfig,
+		codec:  store.GobCodec{},
+	}
+}
+
+// defaultDirectory returns a cachey directory in the user's cache
+// directory, falling back to the temporary directory if there is none.
+func defaultDirectory() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, "cachey")
+}
+
+func (s *FileStore) Init() error {
+	if s.config == nil {
+		return errors.New("file store: configuration is missing")
+	}
+
+	if s.codec == nil {
+		return errors.New("file store: a codec is required")
+	}
+
+	if err := os.MkdirAll(s.config.directory, s.dirMode()); err != nil {
+		return fmt.Errorf("file store: error creating cache directory: %w", err)
+	}
+
+	if s.config.sweepInterval > 0 {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.sweep()
+	}
+
+	return nil
+}
+
+// Close stops the background sweeper.
+func (s *FileStore) Close() error {
+	s.closeOnce.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+			<-s.done
+		}
+	})
+
+	return nil
+}
+
+// Ping checks that the cache directory still exists.
+func (s *FileStore) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(s.config.directory)
+	if err != nil {
+		return fmt.Errorf("file store: error reading cache directory: %w", err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("file store: %s is not a directory", s.config.directory)
+	}
+
+	return nil
+}
+
+func (s *FileStore) SetCodec(codec store.Codec) {
+	s.codec = codec
+}
+
+func (s *FileStore) Codec() store.Codec {
+	return s.codec
+}
+
+func (s *FileStore) Has(key string) (bool, error) {
+	return s.HasCtx(context.Background(), key)
+}
+
+func (s *FileStore) HasCtx(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	entry, err := s.read(s.path(key))
+	if err != nil {
+		return false, err
+	}
+
+	return entry != nil, nil
+}
+
+func (s *FileStore) Get(key string) (any, error) {
+	return s.GetCtx(context.Background(), key)
+}
+
+func (s *FileStore) GetCtx(ctx context.Context, key string) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entry, err := s.read(s.path(key))
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	if entry.counter {
+		return parseCounter(entry.value)
+	}
+
+	data, err := store.Decode(s.codec, entry.value)
+	if err != nil {
+		return nil, fmt.Errorf("file store: %w", err)
+	}
+
+	return data, nil
+}
+
+func (s *FileStore) Put(key string, data any, duration time.Duration) error {
+	return s.PutCtx(context.Background(), key, data, duration)
+}
+
+func (s *FileStore) PutCtx(ctx context.Context, key string, data any, duration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	e, err := s.encode(key, data, duration)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.write(e)
+}
+
+// PutIfAbsent hard links the new file into place, which fails if a file
+// already exists, so that only one of several processes sharing the
+// directory can add a key.
+func (s *FileStore) PutIfAbsent(ctx context.Context, key string, data any, duration time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	e, err := s.encode(key, data, duration)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := s.create(e)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp)
+
+	path := s.path(key)
+	for {
+		err := os.Link(tmp, path)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return false, fmt.Errorf("file store: error writing cache file: %w", err)
+		}
+
+		// an expired entry is replaced like a missing one
+		current, err := s.load(path)
+		if err != nil || (current != nil && !current.expired()) {
+			return false, err
+		}
+
+		if err := s.evict(path, tmp+"-expired"); err != nil {
+			return false, err
+		}
+	}
+}
+
+// Increment adds by to the counter stored under key. Counters are kept as
+// decimal text rather than through the codec.
+func (s *FileStore) Increment(ctx context.Context, key string, by int64, duration time.Duration) (int64, error) {
+	var value int64
+	err := s.updateCounter(ctx, key, duration, func(current []byte) ([]byte, error) {
+		n, err := strconv.ParseInt(string(current), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("counter is not an integer: %w", err)
+		}
+
+		value = n + by
+		return strconv.AppendInt(nil, value, 10), nil
+	})
+
+	return value, err
+}
+
+func (s *FileStore) IncrementFloat(ctx context.Context, key string, by float64, duration time.Duration) (float64, error) {
+	var value float64
+	err := s.updateCounter(ctx, key, duration, func(current []byte) ([]byte, error) {
+		n, err := strconv.ParseFloat(string(current), 64)
+		if err != nil {
+			return nil, fmt.Errorf("counter is not a number: %w", err)
+		}
+
+		value = n + by
+		return strconv.AppendFloat(nil, value, 'g', -1, 64), nil
+	})
+
+	return value, err
+}
+
+// updateCounter replaces the counter stored under key with the result of
+// fn, holding the key's lock file meanwhile. A missing or expired counter
+// is passed to fn as zero and stored with duration; otherwise the expiry
+// is kept.
+func (s *FileStore) updateCounter(ctx context.Context, key string, duration time.Duration, fn func(current []byte) ([]byte, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(key)
+	unlock, err := s.lock(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	e, err := s.load(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case e == nil || e.expired():
+		e = &entry{expiresAt: expiry(duration), counter: true, key: key, value: []byte("0")}
+	case !e.counter:
+		return fmt.Errorf("file store: value of `%s` is not a counter", key)
+	}
+
+	e.value, err = fn(e.value)
+	if err != nil {
+		return fmt.Errorf("file store: error updating counter `%s`: %w", key, err)
+	}
+
+	return s.write(e)
+}
+
+func (s *FileStore) Delete(key string) error {
+	return s.DeleteCtx(context.Background(), key)
+}
+
+func (s *FileStore) DeleteCtx(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("file store: error deleting key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileStore) Flush() error {
+	return s.FlushCtx(context.Background())
+}
+
+func (s *FileStore) FlushCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// only cache files, abandoned temporary and lock files and shard
+	// directories are removed; anything else in the directory belongs to
+	// someone else, including the files of writes still in progress
+	var shards []string
+	err := filepath.WalkDir(s.config.directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path == s.config.directory {
+				return nil
+			}
+			if !s.isShard(path) {
+				return filepath.SkipDir
+			}
+			shards = append(shards, path)
+			return nil
+		}
+
+		if temp, _ := filepath.Match(tempPattern, d.Name()); temp {
+			return removeStale(path, d, staleTempAge)
+		}
+		if strings.HasPrefix(d.Name(), ".") && strings.HasSuffix(d.Name(), lockSuffix) {
+			return removeStale(path, d, staleLockAge)
+		}
+
+		if e, err := s.load(path); err != nil || !s.owns(path, e) {
+			return nil
+		}
+		return remove(path)
+	})
+	if err != nil {
+		return fmt.Errorf("file store: error flushing cache directory: %w", err)
+	}
+
+	// remove the deepest directories first, keeping any that still hold
+	// files the store does not own
+	for i := len(shards) - 1; i >= 0; i-- {
+		if entries, err := os.ReadDir(shards[i]); err != nil || len(entries) > 0 {
+			continue
+		}
+		if err := os.Remove(shards[i]); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("file store: error flushing cache directory: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *FileStore) FlushPrefix(ctx context.Context, prefix string) error {
+	return s.walk(ctx, func(path string, entry *entry) error {
+		if !strings.HasPrefix(entry.key, prefix) {
+			return nil
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		return remove(path)
+	})
+}
+
+// FlushExpired removes every expired file from the cache directory.
+func (s *FileStore) FlushExpired() error {
+	// reading an expired entry removes it, so visiting every file is enough
+	return s.walk(context.Background(), func(string, *entry) error {
+		return nil
+	})
+}
+
+// sweep periodically removes expired files until the store is closed.
+func (s *FileStore) sweep() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.FlushExpired()
+		}
+	}
+}
+
+// entry is the decoded content of a cache file.
+type entry struct {
+	expiresAt int64 // Unix nanoseconds; zero means the entry never expires.
+	counter   bool  // Whether value is a counter in decimal text rather than encoded by the codec.
+	key       string
+	value     []byte
+}
+
+func (e *entry) expired() bool {
+	return e.expiresAt != 0 && time.Now().UnixNano() >= e.expiresAt
+}
+
+// errCorrupt is returned for files that are not valid cache files.
+var errCorrupt = errors.New("cache file is corrupt")
+
+// Cache files start with a fixed-size header: the expiry time followed by
+// the length of the key. Expiry times are never negative, so the top bit
+// of the expiry time marks counters.
+const (
+	headerSize = 8 + 4
+	counterBit = 1 << 63
+)
+
+func encodeEntry(e *entry) []byte {
+	expiresAt := uint64(e.expiresAt)
+	if e.counter {
+		expiresAt |= counterBit
+	}
+
+	buf := make([]byte, headerSize, headerSize+len(e.key)+len(e.value))
+	binary.BigEndian.PutUint64(buf[0:8], expiresAt)
+	binary.BigEndian.PutUint32(buf[8:12], uint32(len(e.key)))
+	buf = append(buf, e.key...)
+	return append(buf, e.value...)
+}
+
+func decodeEntry(buf []byte) (*entry, error) {
+	if len(buf) < headerSize {
+		return nil, errCorrupt
+	}
+
+	keyLen := int(binary.BigEndian.Uint32(buf[8:12]))
+	if len(buf) < headerSize+keyLen {
+		return nil, errCorrupt
+	}
+
+	expiresAt := binary.BigEndian.Uint64(buf[0:8])
+	return &entry{
+		expiresAt: int64(expiresAt &^ counterBit),
+		counter:   expiresAt&counterBit != 0,
+		key:       string(buf[headerSize : headerSize+keyLen]),
+		value:     buf[headerSize+keyLen:],
+	}, nil
+}
+
+// read returns the live entry stored at path, or nil if there is none.
+// Expired entries are removed as they are found.
+func (s *FileStore) read(path string) (*entry, error) {
+	e, err := s.load(path)
+	if err != nil || e == nil {
+		return nil, err
+	}
+
+	if e.expired() {
+		return nil, s.removeExpired(path)
+	}
+
+	return e, nil
+}
+
+// load returns the entry stored at path, expired or not, or nil if there
+// is none.
+func (s *FileStore) load(path string) (*entry, error) {
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("file store: error reading cache file: %w", err)
+	}
+
+	e, err := decodeEntry(buf)
+	if err != nil {
+		return nil, fmt.Errorf("file store: %w", err)
+	}
+
+	return e, nil
+}
+
+// removeExpired removes the file at path if it still holds an expired
+// entry once the lock is held, so a fresh entry written after the expired
+// one was read survives.
+func (s *FileStore) removeExpired(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.load(path)
+	if err != nil || e == nil || !e.expired() {
+		return err
+	}
+
+	return remove(path)
+}
+
+// owns reports whether e, read from path, is a cache file written by the
+// store rather than a foreign file that happens to decode.
+func (s *FileStore) owns(path string, e *entry) bool {
+	return e != nil && s.path(e.key) == path
+}
+
+// isShard reports whether dir is one of the subdirectories the store
+// spreads files across.
+func (s *FileStore) isShard(dir string) bool {
+	rel, err := filepath.Rel(s.config.directory, dir)
+	if err != nil {
+		return false
+	}
+
+	parts := strings.Split(rel, string(filepath.Separator))
+	if len(parts) > s.config.shardDepth {
+		return false
+	}
+
+	for _, part := range parts {
+		if len(part) != 2 || strings.Trim(part, "0123456789abcdef") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// encode returns the entry storing data under key for duration.
+func (s *FileStore) encode(key string, data any, duration time.Duration) (*entry, error) {
+	data, err := store.Encode(s.codec, data)
+	if err != nil {
+		return nil, fmt.Errorf("file store: %w", err)
+	}
+
+	return &entry{expiresAt: expiry(duration), key: key, value: data.([]byte)}, nil
+}
+
+// write atomically replaces the file for e's key with e.
+func (s *FileStore) write(e *entry) error {
+	tmp, err := s.create(e)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if err := os.Rename(tmp, s.path(e.key)); err != nil {
+		return fmt.Errorf("file store: error writing cache file: %w", err)
+	}
+
+	return nil
+}
+
+// create writes e to a new temporary file next to the file for its key
+// and returns the temporary file's name. The caller removes it.
+func (s *FileStore) create(e *entry) (string, error) {
+	dir := filepath.Dir(s.path(e.key))
+	if err := os.MkdirAll(dir, s.dirMode()); err != nil {
+		return "", fmt.Errorf("file store: error creating cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, tempPattern)
+	if err != nil {
+		return "", fmt.Errorf("file store: error creating cache file: %w", err)
+	}
+
+	if _, err := tmp.Write(encodeEntry(e)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("file store: error writing cache file: %w", err)
+	}
+
+	if err := tmp.Chmod(s.config.fileMode); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("file store: error writing cache file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("file store: error writing cache file: %w", err)
+	}
+
+	return tmp.Name(), nil
+}
+
+// evict moves the expired entry at path aside so that a new one can be
+// linked in its place. If another process replaced the entry after it was
+// read, the live entry is moved aside instead, so it is linked back.
+func (s *FileStore) evict(path, aside string) error {
+	if err := os.Rename(path, aside); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("file store: error removing cache file: %w", err)
+	}
+	defer os.Remove(aside)
+
+	e, err := s.load(aside)
+	if errors.Is(err, errCorrupt) || (err == nil && (e == nil || e.expired())) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// a third entry may have been linked in meanwhile, which wins
+	if err := os.Link(aside, path); err != nil && !errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("file store: error restoring cache file: %w", err)
+	}
+
+	return nil
+}
+
+// lock creates the lock file for the cache file at path, waiting while
+// another writer holds it. The returned function releases the lock.
+func (s *FileStore) lock(ctx context.Context, path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), s.dirMode()); err != nil {
+		return nil, fmt.Errorf("file store: error creating cache directory: %w", err)
+	}
+
+	name := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+lockSuffix)
+	for {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, s.config.fileMode)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(name) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("file store: error locking cache file: %w", err)
+		}
+
+		if err := breakStaleLock(name); err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryDelay):
+		}
+	}
+}
+
+// breakStaleLock removes the lock file name if it is older than
+// staleLockAge. The lock file is renamed first, so that when several
+// writers find the same stale lock, none of them removes a fresh lock
+// taken by another in the meantime.
+func breakStaleLock(name string) error {
+	info, err := os.Stat(name)
+	if err != nil || time.Since(info.ModTime()) < staleLockAge {
+		return nil
+	}
+
+	aside := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	if err := os.Rename(name, aside); err != nil {
+		return nil
+	}
+	defer os.Remove(aside)
+
+	if info, err := os.Stat(aside); err == nil && time.Since(info.ModTime()) < staleLockAge {
+		// a fresh lock was renamed by mistake; hand it back
+		if err := os.Link(aside, name); err != nil && !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("file store: error restoring lock file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// walk calls fn for every live entry in the cache directory, removing
+// expired entries and skipping files the store does not own.
+func (s *FileStore) walk(ctx context.Context, fn func(path string, entry *entry) error) error {
+	err := filepath.WalkDir(s.config.directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path != s.config.directory && !s.isShard(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		e, err := s.load(path)
+		if errors.Is(err, errCorrupt) || (err == nil && !s.owns(path, e)) {
+			// not ours to manage
+			return nil
+		}
+		if err != nil || e == nil {
+			return err
+		}
+
+		if e.expired() {
+			return s.removeExpired(path)
+		}
+
+		return fn(path, e)
+	})
+	if err != nil {
+		return fmt.Errorf("file store: error walking cache directory: %w", err)
+	}
+
+	return nil
+}
+
+// path returns the location of the file for key.
+func (s *FileStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+
+	parts := make([]string, 0, s.config.shardDepth+2)
+	parts = append(parts, s.config.directory)
+	for i := 0; i < s.config.shardDepth; i++ {
+		parts = append(parts, name[i*2:i*2+2])
+	}
+
+	return filepath.Join(append(parts, name)...)
+}
+
+// dirMode derives the mode for cache directories from the file mode,
+// adding search permission wherever read permission is granted.
+func (s *FileStore) dirMode() os.FileMode {
+	mode := s.config.fileMode | 0o700
+	return mode | (mode&0o444)>>2
+}
+
+// removeStale deletes the file at path if it was last modified more than
+// age ago.
+func removeStale(path string, d fs.DirEntry, age time.Duration) error {
+	info, err := d.Info()
+	if err != nil || time.Since(info.ModTime()) < age {
+		return nil
+	}
+
+	return remove(path)
+}
+
+// parseCounter returns the value of a counter stored as decimal text.
+func parseCounter(value []byte) (any, error) {
+	if n, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+		return n, nil
+	}
+
+	n, err := strconv.ParseFloat(string(value), 64)
+	if err != nil {
+		return nil, fmt.Errorf("file store: error reading counter: %w", err)
+	}
+
+	return n, nil
+}
+
+// expiry returns the expiry time in unix nanoseconds for an entry stored
+// for duration, or zero if it never expires.
+func expiry(duration time.Duration) int64 {
+	if duration <= 0 {
+		return 0
+	}
+
+	return time.Now().Add(duration).UnixNano()
+}
+
+// remove deletes the file at path, ignoring files that are already gone.
+func remove(path string) error {
+	err := os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("file store: error removing cache file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,433 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	store := NewFileStore().(*FileStore)
+	store.config.directory = t.TempDir()
+	store.config.sweepInterval = 0
+
+	err := store.Init()
+	require.NoError(t, err, "Failed to initialize file store")
+	defer store.Close()
+
+	t.Run("Put and Get", func(t *testing.T) {
+		err := store.Put("key", "value", time.Minute)
+		assert.NoError(t, err)
+
+		val, err := store.Get("key")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", val)
+	})
+
+	t.Run("Get - Key Does Not Exist", func(t *testing.T) {
+		val, err := store.Get("missing")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+	})
+
+	t.Run("Sharded Layout", func(t *testing.T) {
+		path := store.path("key")
+		rel, err := filepath.Rel(store.config.directory, path)
+		assert.NoError(t, err)
+
+		// two levels of directories named after the start of the file name
+		name := filepath.Base(rel)
+		assert.Equal(t, filepath.Join(name[0:2], name[2:4]), filepath.Dir(rel))
+
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	})
+
+	t.Run("Lazy Expiry", func(t *testing.T) {
+		err := store.Put("expiring", "value", 50*time.Millisecond)
+		assert.NoError(t, err)
+
+		time.Sleep(100 * time.Millisecond)
+
+		has, err := store.Has("expiring")
+		assert.NoError(t, err)
+		assert.False(t, has)
+
+		_, err = os.Stat(store.path("expiring"))
+		assert.True(t, os.IsNotExist(err), "Expired file should be removed on read")
+	})
+
+	t.Run("PutIfAbsent", func(t *testing.T) {
+		added, err := store.PutIfAbsent(context.Background(), "key", "other", time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, added)
+
+		added, err = store.PutIfAbsent(context.Background(), "added", "value", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, added)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		err := store.Delete("key")
+		assert.NoError(t, err)
+
+		has, err := store.Has("key")
+		assert.NoError(t, err)
+		assert.False(t, has)
+
+		err = store.Delete("key")
+		assert.NoError(t, err, "Deleting a missing key should not fail")
+	})
+
+	t.Run("FlushPrefix", func(t *testing.T) {
+		_ = store.Put("svc-a:key1", "value1", time.Minute)
+		_ = store.Put("svc-b:key1", "value1", time.Minute)
+
+		err := store.FlushPrefix(context.Background(), "svc-a:")
+		assert.NoError(t, err)
+
+		has, _ := store.Has("svc-a:key1")
+		assert.False(t, has)
+
+		has, _ = store.Has("svc-b:key1")
+		assert.True(t, has)
+	})
+
+	t.Run("Flush", func(t *testing.T) {
+		_ = store.Put("key1", "value1", time.Minute)
+		_ = store.Put("key2", "value2", time.Minute)
+
+		err := store.Flush()
+		assert.NoError(t, err)
+
+		has, _ := store.Has("key1")
+		assert.False(t, has)
+
+		entries, err := os.ReadDir(store.config.directory)
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestFileStore_FlushKeepsForeignFiles(t *testing.T) {
+	store := NewFileStore().(*FileStore)
+	store.config.directory = t.TempDir()
+	store.config.sweepInterval = 0
+	require.NoError(t, store.Init())
+	defer store.Close()
+
+	require.NoError(t, store.Put("key", "value", time.Minute))
+
+	// a file the store did not write, one that merely decodes as a cache
+	// file, and a directory of someone else's
+	foreign := filepath.Join(store.config.directory, "notes.txt")
+	require.NoError(t, os.WriteFile(foreign, []byte("keep me"), 0o600))
+
+	lookalike := filepath.Join(filepath.Dir(store.path("key")), "lookalike")
+	require.NoError(t, os.WriteFile(lookalike, make([]byte, headerSize), 0o600))
+
+	other := filepath.Join(store.config.directory, "other", "data")
+	require.NoError(t, os.MkdirAll(filepath.Dir(other), 0o700))
+	require.NoError(t, os.WriteFile(other, []byte("keep me"), 0o600))
+
+	require.NoError(t, store.Flush())
+
+	has, err := store.Has("key")
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	for _, path := range []string{foreign, lookalike, other} {
+		_, err := os.Stat(path)
+		assert.NoError(t, err, "Foreign file %s should survive a flush", path)
+	}
+
+	// shard directories are removed once they are empty
+	require.NoError(t, os.Remove(lookalike))
+	require.NoError(t, store.Flush())
+
+	entries, err := os.ReadDir(store.config.directory)
+	assert.NoError(t, err)
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{"notes.txt", "other"}, names)
+}
+
+func TestFileStore_ExpiryKeepsNewWrites(t *testing.T) {
+	store := NewFileStore().(*FileStore)
+	store.config.directory = t.TempDir()
+	store.config.sweepInterval = 0
+	require.NoError(t, store.Init())
+	defer store.Close()
+
+	path := store.path("key")
+	require.NoError(t, store.Put("key", "old", 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	// the expired entry was read, then replaced before it could be removed
+	stale, err := store.load(path)
+	require.NoError(t, err)
+	require.True(t, stale.expired())
+
+	require.NoError(t, store.Put("key", "new", time.Minute))
+	require.NoError(t, store.removeExpired(path))
+
+	val, err := store.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "new", val)
+}
+
+// openStores returns n file stores sharing directory, standing in for
+// several processes.
+func openStores(t *testing.T, directory string, n int) []*FileStore {
+	stores := make([]*FileStore, n)
+	for i := range stores {
+		s := NewFileStore().(*FileStore)
+		s.config.directory = directory
+		s.config.sweepInterval = 0
+		require.NoError(t, s.Init())
+		t.Cleanup(func() { s.Close() })
+		stores[i] = s
+	}
+	return stores
+}
+
+func TestFileStore_PutIfAbsentAcrossStores(t *testing.T) {
+	stores := openStores(t, t.TempDir(), 8)
+
+	var added atomic.Int32
+	var wg sync.WaitGroup
+	for _, s := range stores {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := s.PutIfAbsent(context.Background(), "key", "value", time.Minute)
+			assert.NoError(t, err)
+			if ok {
+				added.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), added.Load(), "Only one store should add the key")
+
+	require.NoError(t, stores[0].Put("expiring", "old", 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	ok, err := stores[1].PutIfAbsent(context.Background(), "expiring", "new", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok, "An expired entry should be replaced")
+
+	val, err := stores[0].Get("expiring")
+	assert.NoError(t, err)
+	assert.Equal(t, "new", val)
+
+	entries, err := os.ReadDir(filepath.Dir(stores[0].path("expiring")))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "No temporary files should be left behind")
+}
+
+func TestFileStore_FlushKeepsFreshTempFiles(t *testing.T) {
+	store := NewFileStore().(*FileStore)
+	store.config.directory = t.TempDir()
+	store.config.sweepInterval = 0
+	require.NoError(t, store.Init())
+	defer store.Close()
+
+	// another process is writing fresh, while stale was abandoned
+	fresh := filepath.Join(store.config.directory, ".tmp-fresh")
+	stale := filepath.Join(store.config.directory, ".tmp-stale")
+	for _, path := range []string{fresh, stale} {
+		require.NoError(t, os.WriteFile(path, nil, 0o600))
+	}
+	old := time.Now().Add(-2 * staleTempAge)
+	require.NoError(t, os.Chtimes(stale, old, old))
+
+	require.NoError(t, store.Flush())
+
+	_, err := os.Stat(fresh)
+	assert.NoError(t, err, "A write in progress should survive a flush")
+
+	_, err = os.Stat(stale)
+	assert.True(t, os.IsNotExist(err), "An abandoned temporary file should be removed")
+}
+
+func TestFileStore_Increment(t *testing.T) {
+	store := NewFileStore().(*FileStore)
+	store.config.directory = t.TempDir()
+	store.config.sweepInterval = 0
+	require.NoError(t, store.Init())
+	defer store.Close()
+
+	ctx := context.Background()
+
+	val, err := store.Increment(ctx, "counter", 2, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), val)
+
+	before, err := store.load(store.path("counter"))
+	require.NoError(t, err)
+
+	val, err = store.Increment(ctx, "counter", -5, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-3), val)
+
+	after, err := store.load(store.path("counter"))
+	require.NoError(t, err)
+	assert.Equal(t, before.expiresAt, after.expiresAt, "Incrementing should keep the expiry")
+
+	got, err := store.Get("counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-3), got)
+
+	floatVal, err := store.IncrementFloat(ctx, "float", 0.5, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, floatVal)
+
+	floatVal, err = store.IncrementFloat(ctx, "float", 0.25, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.75, floatVal)
+
+	_, err = store.Increment(ctx, "float", 1, time.Minute)
+	assert.Error(t, err, "A floating point counter is not an integer")
+
+	require.NoError(t, store.Put("text", "value", time.Minute))
+	_, err = store.Increment(ctx, "text", 1, time.Minute)
+	assert.Error(t, err, "Values stored through the codec are not counters")
+
+	require.NoError(t, store.Put("expiring", "value", 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	val, err = store.Increment(ctx, "expiring", 1, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), val, "An expired entry should start again from zero")
+}
+
+func TestFileStore_IncrementAcrossStores(t *testing.T) {
+	stores := openStores(t, t.TempDir(), 4)
+
+	var wg sync.WaitGroup
+	for _, s := range stores {
+		for range 25 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.Increment(context.Background(), "counter", 1, time.Minute)
+				assert.NoError(t, err)
+			}()
+		}
+	}
+	wg.Wait()
+
+	val, err := stores[0].Get("counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), val)
+}
+
+func TestFileStore_IncrementBreaksStaleLock(t *testing.T) {
+	store := NewFileStore().(*FileStore)
+	store.config.directory = t.TempDir()
+	store.config.sweepInterval = 0
+	require.NoError(t, store.Init())
+	defer store.Close()
+
+	// a process died while holding the lock
+	path := store.path("counter")
+	lock := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+lockSuffix)
+	require.NoError(t, os.MkdirAll(filepath.Dir(lock), 0o700))
+	require.NoError(t, os.WriteFile(lock, nil, 0o600))
+	old := time.Now().Add(-2 * staleLockAge)
+	require.NoError(t, os.Chtimes(lock, old, old))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	val, err := store.Increment(ctx, "counter", 1, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), val)
+
+	_, err = os.Stat(lock)
+	assert.True(t, os.IsNotExist(err), "The lock should be released")
+}
+
+func TestFileStore_SurvivesRestart(t *testing.T) {
+	directory := t.TempDir()
+
+	first := NewFileStore()
+	require.NoError(t, WithDirectory(directory)(first))
+	require.NoError(t, first.Init())
+	require.NoError(t, first.Put("key", "value", time.Minute))
+	first.(*FileStore).Close()
+
+	second := NewFileStore()
+	require.NoError(t, WithDirectory(directory)(second))
+	require.NoError(t, second.Init())
+	defer second.(*FileStore).Close()
+
+	val, err := second.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+}
+
+func TestFileStore_Sweeper(t *testing.T) {
+	store := NewFileStore().(*FileStore)
+	require.NoError(t, WithDirectory(t.TempDir())(store))
+	require.NoError(t, WithSweepInterval(50*time.Millisecond)(store))
+	require.NoError(t, store.Init())
+	defer store.Close()
+
+	require.NoError(t, store.Put("expiring", "value", 10*time.Millisecond))
+	require.NoError(t, store.Put("forever", "value", -1))
+
+	time.Sleep(200 * time.Millisecond)
+
+	_, err := os.Stat(store.path("expiring"))
+	assert.True(t, os.IsNotExist(err), "Expired file should be swept")
+
+	_, err = os.Stat(store.path("forever"))
+	assert.NoError(t, err, "Live file should not be swept")
+}
+
+func TestNewFileStore_DefaultDirectory(t *testing.T) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		t.Skip("no user cache directory")
+	}
+
+	store := NewFileStore().(*FileStore)
+	assert.Equal(t, filepath.Join(cacheDir, "cachey"), store.config.directory)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{FileMode: os.ModeDir | 0o600, ShardDepth: maxShardDepth + 1})
+	var fields []string
+	for _, fieldError := range store.FieldErrors(err) {
+		fields = append(fields, fieldError.Field)
+	}
+	assert.Equal(t, []string{"FileMode", "ShardDepth"}, fields)
+
+	fileStore, err := New(Config{Directory: t.TempDir(), ShardDepth: -1, SweepInterval: -1})
+	require.NoError(t, err)
+	require.NoError(t, fileStore.Init())
+	defer fileStore.Close()
+
+	assert.Equal(t, 0, fileStore.config.shardDepth, "A negative depth should keep files flat")
+	assert.Equal(t, time.Duration(0), fileStore.config.sweepInterval, "A negative interval should disable the sweeper")
+	assert.Equal(t, os.FileMode(0o600), fileStore.config.fileMode)
+
+	require.NoError(t, fileStore.Put("key", "value", time.Minute))
+	entries, _ := os.ReadDir(fileStore.config.directory)
+	assert.Len(t, entries, 1)
+	assert.False(t, entries[0].IsDir())
+}
@@ -0,0 +1,47 @@
+package store
+
+import "context"
+
+// Invalidation describes a change another process made to a shared store.
+type Invalidation struct {
+	Keys   []string // Keys that were written or deleted.
+	Prefix string   // Set when every key starting with Prefix was removed.
+	Flush  bool     // Set when the whole store was flushed.
+}
+
+// Invalidator is implemented by shared stores that report changes made by
+// other processes, so that local copies of their entries can be evicted.
+type Invalidator interface {
+	// OnInvalidate registers fn to be called for every change made to the
+	// store by another process. Changes made through the store itself are
+	// not reported.
+	OnInvalidate(fn func(Invalidation))
+}
+
+// Evict removes the entries described by inv from s. Stores that cannot
+// flush by prefix are flushed entirely when a prefix is invalidated, which
+// is safe for a local copy of a shared store.
+func Evict(ctx context.Context, s Store, inv Invalidation) error {
+	cs := WithContext(s)
+
+	switch {
+	case inv.Flush:
+		return cs.FlushCtx(ctx)
+	case inv.Prefix != "":
+		if flusher, ok := s.(PrefixFlusher); ok {
+			return flusher.FlushPrefix(ctx, inv.Prefix)
+		}
+		return cs.FlushCtx(ctx)
+	}
+
+	if batch, ok := s.(BatchStore); ok {
+		return batch.DeleteMany(ctx, inv.Keys)
+	}
+
+	for _, key := range inv.Keys {
+		if err := cs.DeleteCtx(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package memcached
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeItem is an item held by fakeServer.
+type fakeItem struct {
+	value      []byte
+	flags      uint32
+	cas        uint64
+	expiration int64 // Raw expiration as sent by the client.
+	expiresAt  time.Time
+}
+
+// fakeServer is an in-process server speaking the subset of the memcached
+// text protocol used by the store.
+type fakeServer struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	items   map[string]*fakeItem
+	nextCAS uint64
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error starting fake memcached server: %v", err)
+	}
+
+	server := &fakeServer{listener: listener, items: make(map[string]*fakeItem)}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+
+	return server
+}
+
+func (s *fakeServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// item returns the live item stored under key.
+func (s *fakeServer) item(key string) (*fakeItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.get(key)
+}
+
+func (s *fakeServer) get(key string) (*fakeItem, bool) {
+	item, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	if !item.expiresAt.IsZero() && !time.Now().Before(item.expiresAt) {
+		delete(s.items, key)
+		return nil, false
+	}
+	return item, true
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if err := s.command(rw, fields); err != nil {
+			return
+		}
+
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) command(rw *bufio.ReadWriter, fields []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch fields[0] {
+	case "get", "gets":
+		for _, key := range fields[1:] {
+			if item, ok := s.get(key); ok {
+				fmt.Fprintf(rw, "VALUE %s %d %d %d\r\n%s\r\n", key, item.flags, len(item.value), item.cas, item.value)
+			}
+		}
+		rw.WriteString("END\r\n")
+
+	case "set", "add", "cas":
+		flags, _ := strconv.ParseUint(fields[2], 10, 32)
+		exptime, _ := strconv.ParseInt(fields[3], 10, 64)
+		size, _ := strconv.Atoi(fields[4])
+
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(rw, value); err != nil {
+			return err
+		}
+		value = value[:size]
+
+		existing, exists := s.get(fields[1])
+		switch {
+		case fields[0] == "add" && exists:
+			rw.WriteString("NOT_STORED\r\n")
+			return nil
+		case fields[0] == "cas" && !exists:
+			rw.WriteString("NOT_FOUND\r\n")
+			return nil
+		case fields[0] == "cas":
+			casID, _ := strconv.ParseUint(fields[5], 10, 64)
+			if casID != existing.cas {
+				rw.WriteString("EXISTS\r\n")
+				return nil
+			}
+		}
+
+		s.nextCAS++
+		s.items[fields[1]] = &fakeItem{
+			value:      value,
+			flags:      uint32(flags),
+			cas:        s.nextCAS,
+			expiration: exptime,
+			expiresAt:  expiresAt(exptime),
+		}
+		rw.WriteString("STORED\r\n")
+
+	case "incr", "decr":
+		item, ok := s.get(fields[1])
+		if !ok {
+			rw.WriteString("NOT_FOUND\r\n")
+			return nil
+		}
+
+		value, err := strconv.ParseUint(strings.TrimSpace(string(item.value)), 10, 64)
+		if err != nil {
+			rw.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+			return nil
+		}
+
+		delta, _ := strconv.ParseUint(fields[2], 10, 64)
+		switch {
+		case fields[0] == "incr":
+			value += delta
+		case delta > value:
+			value = 0
+		default:
+			value -= delta
+		}
+
+		s.nextCAS++
+		item.value = []byte(strconv.FormatUint(value, 10))
+		item.cas = s.nextCAS
+		fmt.Fprintf(rw, "%d\r\n", value)
+
+	case "delete":
+		if _, ok := s.get(fields[1]); !ok {
+			rw.WriteString("NOT_FOUND\r\n")
+			return nil
+		}
+		delete(s.items, fields[1])
+		rw.WriteString("DELETED\r\n")
+
+	case "flush_all":
+		s.items = make(map[string]*fakeItem)
+		rw.WriteString("OK\r\n")
+
+	case "version":
+		rw.WriteString("VERSION fake\r\n")
+
+	default:
+		rw.WriteString("ERROR\r\n")
+	}
+
+	return nil
+}
+
+// expiresAt interprets a memcached expiration time: zero never expires,
+// values up to 30 days are relative seconds and larger values are unix
+// timestamps.
+func expiresAt(exptime int64) time.Time {
+	switch {
+	case exptime == 0:
+		return time.Time{}
+	case exptime <= int64(maxRelativeExpiration/time.Second):
+		return time.Now().Add(time.Duration(exptime) * time.Second)
+	default:
+		return time.Unix(exptime, 0)
+	}
+}
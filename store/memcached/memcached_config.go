@@ -0,0 +1,84 @@
+package memcached
+
+import (
+	"errors"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+// Config configures a memcached store created with New. Zero fields take
+// the same defaults as NewMemcachedStore.
+type Config struct {
+	// Servers are the host:port addresses keys are spread across. Defaults
+	// to localhost:11211.
+	Servers []string
+
+	// Timeout bounds every request. Defaults to 500 milliseconds.
+	Timeout time.Duration
+
+	// MaxIdleConns is the number of idle connections kept per server.
+	// Defaults to 2.
+	MaxIdleConns int
+
+	// Replicas is how many points each server occupies on the consistent
+	// hash ring. Defaults to 160.
+	Replicas int
+
+	// Codec serializes values. Defaults to store.GobCodec.
+	Codec store.Codec
+}
+
+// New returns a memcached store configured by cfg, or the field errors of
+// an invalid cfg. Pass the store to cachey.NewFromStore, which initializes
+// it and connects.
+func New(cfg Config) (*MemcachedStore, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	s := NewMemcachedStore().(*MemcachedStore)
+	if len(cfg.Servers) > 0 {
+		s.config.servers = cfg.Servers
+	}
+	if cfg.Timeout != 0 {
+		s.config.timeout = cfg.Timeout
+	}
+	if cfg.MaxIdleConns != 0 {
+		s.config.maxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.Replicas != 0 {
+		s.config.replicas = cfg.Replicas
+	}
+	if cfg.Codec != nil {
+		s.codec = cfg.Codec
+	}
+
+	return s, nil
+}
+
+func (cfg Config) validate() error {
+	var errs []error
+	invalid := func(field, reason string) {
+		errs = append(errs, &store.FieldError{Store: "memcached", Field: field, Reason: reason})
+	}
+
+	for _, server := range cfg.Servers {
+		if server == "" {
+			invalid("Servers", "must not contain empty addresses")
+			break
+		}
+	}
+
+	if cfg.Timeout < 0 {
+		invalid("Timeout", "must not be negative")
+	}
+	if cfg.MaxIdleConns < 0 {
+		invalid("MaxIdleConns", "must not be negative")
+	}
+	if cfg.Replicas < 0 {
+		invalid("Replicas", "must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
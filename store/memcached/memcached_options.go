@@ -0,0 +1,74 @@
+package memcached
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+// WithServers sets the memcached servers keys are spread across. At
+// least one server is required.
+func WithServers(servers ...string) store.Option {
+	return func(s store.Store) error {
+		memcachedStore, ok := s.(*MemcachedStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for memcached options")
+		}
+
+		if len(servers) == 0 {
+			return fmt.Errorf("memcached store: at least one server is required")
+		}
+		for _, server := range servers {
+			if server == "" {
+				return fmt.Errorf("memcached store: server addresses must not be empty")
+			}
+		}
+
+		memcachedStore.config.servers = servers
+		return nil
+	}
+}
+
+func WithTimeout(timeout time.Duration) store.Option {
+	return func(s store.Store) error {
+		memcachedStore, ok := s.(*MemcachedStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for memcached options")
+		}
+
+		memcachedStore.config.timeout = timeout
+		return nil
+	}
+}
+
+func WithMaxIdleConns(maxIdleConns int) store.Option {
+	return func(s store.Store) error {
+		memcachedStore, ok := s.(*MemcachedStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for memcached options")
+		}
+
+		memcachedStore.config.maxIdleConns = maxIdleConns
+		return nil
+	}
+}
+
+// WithReplicas sets how many points each server occupies on the
+// consistent hash ring. More points give a more even spread of keys;
+// the count must be positive.
+func WithReplicas(replicas int) store.Option {
+	return func(s store.Store) error {
+		memcachedStore, ok := s.(*MemcachedStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for memcached options")
+		}
+
+		if replicas <= 0 {
+			return fmt.Errorf("memcached store: replicas must be positive")
+		}
+
+		memcachedStore.config.replicas = replicas
+		return nil
+	}
+}
@@ -0,0 +1,404 @@
+package memcached
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/codemaestro64/cachey/store"
+)
+
+type config struct {
+	servers      []string
+	timeout      time.Duration
+	maxIdleConns int
+	replicas     int
+}
+
+type MemcachedStore struct {
+	config *config
+	client *memcache.Client
+	codec  store.Codec
+}
+
+func NewMemcachedStore() store.Store {
+	defaultConfig := config{
+		servers:      []string{"localhost:11211"},
+		timeout:      500 * time.Millisecond,
+		maxIdleConns: 2,
+		replicas:     160,
+	}
+
+	return &MemcachedStore{
+		config: &defaultConfig,
+		codec:  store.GobCodec{},
+	}
+}
+
+func (s *MemcachedStore) Init() error {
+	if s.config == nil {
+		return errors.New("memcached store: configuration is missing")
+	}
+
+	if s.codec == nil {
+		return errors.New("memcached store: a codec is required")
+	}
+
+	// a ring without points has no server to pick, yet pinging it succeeds
+	if len(s.config.servers) == 0 {
+		return errors.New("memcached store: at least one server is required")
+	}
+
+	if s.config.replicas <= 0 {
+		return errors.New("memcached store: replicas must be positive")
+	}
+
+	selector, err := newRing(s.config.servers, s.config.replicas)
+	if err != nil {
+		return fmt.Errorf("memcached store: error resolving servers: %w", err)
+	}
+
+	s.client = memcache.NewFromSelector(selector)
+	s.client.Timeout = s.config.timeout
+	s.client.MaxIdleConns = s.config.maxIdleConns
+
+	if err := s.client.Ping(); err != nil {
+		return fmt.Errorf("memcached store: error pinging servers: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the connections to the memcached servers.
+func (s *MemcachedStore) Close() error {
+	if s.client == nil {
+		return nil
+	}
+
+	return s.client.Close()
+}
+
+// Ping checks that every memcached server responds.
+func (s *MemcachedStore) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := s.client.Ping(); err != nil {
+		return fmt.Errorf("memcached store: error pinging servers: %w", err)
+	}
+
+	return nil
+}
+
+func (s *MemcachedStore) SetCodec(codec store.Codec) {
+	s.codec = codec
+}
+
+func (s *MemcachedStore) Codec() store.Codec {
+	return s.codec
+}
+
+func (s *MemcachedStore) Has(key string) (bool, error) {
+	return s.HasCtx(context.Background(), key)
+}
+
+func (s *MemcachedStore) HasCtx(ctx context.Context, key string) (bool, error) {
+	data, err := s.GetCtx(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	return data != nil, nil
+}
+
+func (s *MemcachedStore) Get(key string) (any, error) {
+	return s.GetCtx(context.Background(), key)
+}
+
+func (s *MemcachedStore) GetCtx(ctx context.Context, key string) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	item, err := s.client.Get(storeKey(key))
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("memcached store: error getting cache data: %w", err)
+	}
+
+	return s.decode(item)
+}
+
+func (s *MemcachedStore) Put(key string, data any, duration time.Duration) error {
+	return s.PutCtx(context.Background(), key, data, duration)
+}
+
+func (s *MemcachedStore) PutCtx(ctx context.Context, key string, data any, duration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	item, err := s.item(key, data, duration)
+	if err != nil {
+		return err
+	}
+
+	if err := s.client.Set(item); err != nil {
+		return fmt.Errorf("memcached store: error saving item to the store: %w", err)
+	}
+
+	return nil
+}
+
+// PutIfAbsent uses memcached's add command, which stores an item only if
+// no item exists under its key. It deliberately does not use gets and cas:
+// cas can only replace an existing item, so adding a missing one takes the
+// add command either way, and add alone is already atomic on the server.
+func (s *MemcachedStore) PutIfAbsent(ctx context.Context, key string, data any, duration time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	item, err := s.item(key, data, duration)
+	if err != nil {
+		return false, err
+	}
+
+	err = s.client.Add(item)
+	if errors.Is(err, memcache.ErrNotStored) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("memcached store: error adding item to the store: %w", err)
+	}
+
+	return true, nil
+}
+
+// Increment uses memcached's incr and decr commands. Counters are stored
+// as decimal text rather than through the codec, and are unsigned, as in
+// memcached: a decrement never takes a counter below zero.
+func (s *MemcachedStore) Increment(ctx context.Context, key string, by int64, duration time.Duration) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	k := storeKey(key)
+	for {
+		var value uint64
+		var err error
+		if by >= 0 {
+			value, err = s.client.Increment(k, uint64(by))
+		} else {
+			value, err = s.client.Decrement(k, uint64(-by))
+		}
+		if err == nil {
+			return int64(value), nil
+		}
+		if !errors.Is(err, memcache.ErrCacheMiss) {
+			return 0, fmt.Errorf("memcached store: error updating counter `%s`: %w", key, err)
+		}
+
+		// missing counters are created with the increment as their value;
+		// if another client creates it first, update theirs instead
+		initial := max(by, 0)
+		err = s.client.Add(&memcache.Item{
+			Key:        k,
+			Value:      []byte(strconv.FormatInt(initial, 10)),
+			Flags:      counterFlag,
+			Expiration: expiration(duration, time.Now()),
+		})
+		if err == nil {
+			return initial, nil
+		}
+		if !errors.Is(err, memcache.ErrNotStored) {
+			return 0, fmt.Errorf("memcached store: error updating counter `%s`: %w", key, err)
+		}
+	}
+}
+
+// IncrementFloat is not supported: memcached only has integer counters,
+// and a cas loop would lose the counter's expiry, which memcached does not
+// report.
+func (s *MemcachedStore) IncrementFloat(ctx context.Context, key string, by float64, duration time.Duration) (float64, error) {
+	return 0, fmt.Errorf("memcached store: floating point counters are not supported: %w", errors.ErrUnsupported)
+}
+
+func (s *MemcachedStore) GetMany(ctx context.Context, keys []string) (map[string]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	storeKeys := make([]string, len(keys))
+	for i, key := range keys {
+		storeKeys[i] = storeKey(key)
+	}
+
+	found, err := s.client.GetMulti(storeKeys)
+	if err != nil {
+		return nil, fmt.Errorf("memcached store: error getting cache data: %w", err)
+	}
+
+	items := make(map[string]any, len(found))
+	for i, key := range keys {
+		item, ok := found[storeKeys[i]]
+		if !ok {
+			continue
+		}
+
+		data, err := s.decode(item)
+		if err != nil {
+			return nil, err
+		}
+		items[key] = data
+	}
+
+	return items, nil
+}
+
+func (s *MemcachedStore) PutMany(ctx context.Context, items map[string]any, duration time.Duration) error {
+	for key, data := range items {
+		if err := s.PutCtx(ctx, key, data, duration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *MemcachedStore) DeleteMany(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		if err := s.DeleteCtx(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *MemcachedStore) Delete(key string) error {
+	return s.DeleteCtx(context.Background(), key)
+}
+
+func (s *MemcachedStore) DeleteCtx(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := s.client.Delete(storeKey(key))
+	if err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+		return fmt.Errorf("memcached store: error deleting key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *MemcachedStore) Flush() error {
+	return s.FlushCtx(context.Background())
+}
+
+func (s *MemcachedStore) FlushCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := s.client.FlushAll(); err != nil {
+		return fmt.Errorf("memcached store: error flushing servers: %w", err)
+	}
+
+	return nil
+}
+
+// counterFlag marks items holding a counter, which are stored as decimal
+// text for incr and decr instead of through the codec.
+const counterFlag = 1
+
+// decode returns the value held by item.
+func (s *MemcachedStore) decode(item *memcache.Item) (any, error) {
+	if item.Flags&counterFlag != 0 {
+		// decr may leave trailing spaces where digits were dropped
+		value, err := strconv.ParseInt(strings.TrimSpace(string(item.Value)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("memcached store: error reading counter: %w", err)
+		}
+		return value, nil
+	}
+
+	data, err := store.Decode(s.codec, item.Value)
+	if err != nil {
+		return nil, fmt.Errorf("memcached store: %w", err)
+	}
+
+	return data, nil
+}
+
+// item encodes data into a memcached item for key.
+func (s *MemcachedStore) item(key string, data any, duration time.Duration) (*memcache.Item, error) {
+	data, err := store.Encode(s.codec, data)
+	if err != nil {
+		return nil, fmt.Errorf("memcached store: %w", err)
+	}
+
+	return &memcache.Item{
+		Key:        storeKey(key),
+		Value:      data.([]byte),
+		Expiration: expiration(duration, time.Now()),
+	}, nil
+}
+
+// maxRelativeExpiration is the longest expiration memcached reads as a
+// number of seconds from now. Larger values are read as a unix timestamp.
+const maxRelativeExpiration = 30 * 24 * time.Hour
+
+// expiration converts a cache duration into a memcached expiration time.
+// Zero and negative durations never expire. Durations are rounded up to
+// whole seconds, and durations beyond 30 days are sent as an absolute
+// unix timestamp, as memcached requires.
+func expiration(duration time.Duration, now time.Time) int32 {
+	if duration <= 0 {
+		return 0
+	}
+
+	if duration > maxRelativeExpiration {
+		return int32(now.Add(duration).Unix())
+	}
+
+	seconds := (duration + time.Second - 1) / time.Second
+	return int32(seconds)
+}
+
+// maxKeyLength is the longest key memcached accepts.
+const maxKeyLength = 250
+
+// storeKey returns a key memcached accepts for key. Keys that are too long
+// or contain whitespace or control characters are replaced by a hash.
+func storeKey(key string) string {
+	if len(key) <= maxKeyLength && legalKey(key) {
+		return key
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	return "cachey:sha256:" + hex.EncodeToString(sum[:])
+}
+
+func legalKey(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,287 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMemcachedStore runs unit tests for MemcachedStore against a fake server
+func TestMemcachedStore(t *testing.T) {
+	server := newFakeServer(t)
+
+	store := NewMemcachedStore().(*MemcachedStore)
+	require.NoError(t, WithServers(server.Addr())(store))
+
+	err := store.Init()
+	require.NoError(t, err, "Failed to initialize memcached store")
+	defer store.Close()
+
+	t.Run("Put and Get", func(t *testing.T) {
+		err := store.Put("test_key", "test_value", 10*time.Second)
+		assert.NoError(t, err, "Failed to set value in memcached")
+
+		val, err := store.Get("test_key")
+		assert.NoError(t, err, "Failed to get value from memcached")
+		assert.Equal(t, "test_value", val)
+
+		item, ok := server.item("test_key")
+		assert.True(t, ok)
+		assert.Equal(t, int64(10), item.expiration, "TTL should be sent in seconds")
+	})
+
+	t.Run("Get - Key Does Not Exist", func(t *testing.T) {
+		val, err := store.Get("non_existent_key")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+
+		exists, err := store.Has("non_existent_key")
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("Forever", func(t *testing.T) {
+		err := store.Put("forever_key", "value", -1)
+		assert.NoError(t, err)
+
+		item, ok := server.item("forever_key")
+		assert.True(t, ok)
+		assert.Equal(t, int64(0), item.expiration, "Forever items should not expire")
+	})
+
+	t.Run("Long TTL", func(t *testing.T) {
+		err := store.Put("long_key", "value", 60*24*time.Hour)
+		assert.NoError(t, err)
+
+		item, ok := server.item("long_key")
+		assert.True(t, ok)
+		assert.InDelta(t, time.Now().Add(60*24*time.Hour).Unix(), item.expiration, 2, "Long TTLs should be absolute")
+
+		val, err := store.Get("long_key")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", val)
+	})
+
+	t.Run("Illegal Keys", func(t *testing.T) {
+		key := "key with spaces " + strings.Repeat("x", 300)
+		err := store.Put(key, "value", 10*time.Second)
+		assert.NoError(t, err)
+
+		val, err := store.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, "value", val)
+	})
+
+	t.Run("PutIfAbsent", func(t *testing.T) {
+		added, err := store.PutIfAbsent(context.Background(), "test_key", "other", 10*time.Second)
+		assert.NoError(t, err)
+		assert.False(t, added, "Existing key should not be overwritten")
+
+		added, err = store.PutIfAbsent(context.Background(), "added_key", "value", 10*time.Second)
+		assert.NoError(t, err)
+		assert.True(t, added, "Missing key should be added")
+	})
+
+	t.Run("Increment", func(t *testing.T) {
+		ctx := context.Background()
+
+		val, err := store.Increment(ctx, "counter", 5, 10*time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), val)
+
+		val, err = store.Increment(ctx, "counter", -2, 10*time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), val)
+
+		cached, err := store.Get("counter")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), cached)
+
+		// the expiry set when the counter was created is kept
+		item, ok := server.item("counter")
+		require.True(t, ok)
+		assert.Equal(t, int64(10), item.expiration)
+
+		// counters do not go below zero
+		val, err = store.Increment(ctx, "counter", -10, 10*time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), val)
+
+		// values that are not counters are rejected
+		_, err = store.Increment(ctx, "test_key", 1, 10*time.Second)
+		assert.Error(t, err)
+
+		_, err = store.IncrementFloat(ctx, "float", 0.5, 10*time.Second)
+		assert.ErrorIs(t, err, errors.ErrUnsupported)
+	})
+
+	t.Run("Increment Concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				_, err := store.Increment(context.Background(), "concurrent", 1, 10*time.Second)
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		// increments racing to create the counter are not lost
+		val, err := store.Get("concurrent")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(20), val)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		items := map[string]any{"batch1": "value1", "batch2": "value2"}
+
+		err := store.PutMany(context.Background(), items, 10*time.Second)
+		assert.NoError(t, err)
+
+		vals, err := store.GetMany(context.Background(), []string{"batch1", "batch2", "missing"})
+		assert.NoError(t, err)
+		assert.Equal(t, items, vals)
+
+		err = store.DeleteMany(context.Background(), []string{"batch1", "batch2", "missing"})
+		assert.NoError(t, err)
+
+		vals, err = store.GetMany(context.Background(), []string{"batch1", "batch2"})
+		assert.NoError(t, err)
+		assert.Empty(t, vals)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		err := store.Delete("test_key")
+		assert.NoError(t, err)
+
+		exists, err := store.Has("test_key")
+		assert.NoError(t, err)
+		assert.False(t, exists)
+
+		err = store.Delete("test_key")
+		assert.NoError(t, err, "Deleting a missing key should not fail")
+	})
+
+	t.Run("Flush", func(t *testing.T) {
+		_ = store.Put("key1", "value1", 10*time.Second)
+
+		err := store.Flush()
+		assert.NoError(t, err)
+
+		exists, _ := store.Has("key1")
+		assert.False(t, exists)
+	})
+
+	t.Run("Expiration", func(t *testing.T) {
+		err := store.Put("expiring_key", "value", 500*time.Millisecond)
+		assert.NoError(t, err)
+
+		item, ok := server.item("expiring_key")
+		assert.True(t, ok)
+		assert.Equal(t, int64(1), item.expiration, "Sub-second TTLs should round up")
+
+		time.Sleep(1100 * time.Millisecond)
+		exists, _ := store.Has("expiring_key")
+		assert.False(t, exists)
+	})
+}
+
+func TestMemcachedStore_ConsistentHashing(t *testing.T) {
+	servers := []*fakeServer{newFakeServer(t), newFakeServer(t), newFakeServer(t)}
+	addrs := make([]string, len(servers))
+	for i, server := range servers {
+		addrs[i] = server.Addr()
+	}
+
+	store := NewMemcachedStore().(*MemcachedStore)
+	require.NoError(t, WithServers(addrs...)(store))
+	require.NoError(t, store.Init())
+	defer store.Close()
+
+	for i := 0; i < 300; i++ {
+		require.NoError(t, store.Put(fmt.Sprintf("key%d", i), "value", time.Minute))
+	}
+
+	// every server receives a share of the keys
+	for _, server := range servers {
+		server.mu.Lock()
+		assert.NotEmpty(t, server.items)
+		server.mu.Unlock()
+	}
+
+	// removing a server only moves the keys it owned
+	full, err := newRing(addrs, 160)
+	require.NoError(t, err)
+	reduced, err := newRing(addrs[:2], 160)
+	require.NoError(t, err)
+
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("key%d", i)
+		before, _ := full.PickServer(key)
+		after, _ := reduced.PickServer(key)
+		if before.String() != addrs[2] {
+			assert.Equal(t, before.String(), after.String(), "Key %s should stay on its server", key)
+		}
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	assert.Equal(t, int32(0), expiration(0, now))
+	assert.Equal(t, int32(0), expiration(-1, now))
+	assert.Equal(t, int32(1), expiration(time.Millisecond, now))
+	assert.Equal(t, int32(90), expiration(90*time.Second, now))
+	assert.Equal(t, int32(maxRelativeExpiration/time.Second), expiration(maxRelativeExpiration, now))
+	assert.Equal(t, int32(now.Add(31*24*time.Hour).Unix()), expiration(31*24*time.Hour, now))
+}
+
+func TestMemcachedStore_Options(t *testing.T) {
+	store := NewMemcachedStore()
+
+	assert.Error(t, WithServers()(store), "At least one server is required")
+	assert.Error(t, WithServers("")(store))
+	assert.Error(t, WithReplicas(0)(store))
+	assert.Error(t, WithReplicas(-1)(store))
+
+	memcachedStore := store.(*MemcachedStore)
+	memcachedStore.config.servers = nil
+	assert.Error(t, memcachedStore.Init(), "Init should not succeed without servers")
+
+	memcachedStore.config.servers = []string{newFakeServer(t).Addr()}
+	memcachedStore.config.replicas = 0
+	assert.Error(t, memcachedStore.Init(), "Init should not succeed without ring points")
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{Servers: []string{""}, Timeout: -1, Replicas: -1})
+	var fields []string
+	for _, fieldError := range store.FieldErrors(err) {
+		fields = append(fields, fieldError.Field)
+	}
+	assert.Equal(t, []string{"Servers", "Timeout", "Replicas"}, fields)
+
+	server := newFakeServer(t)
+	memcachedStore, err := New(Config{Servers: []string{server.Addr()}, Timeout: time.Second})
+	require.NoError(t, err)
+	require.NoError(t, memcachedStore.Init())
+	defer memcachedStore.Close()
+
+	assert.Equal(t, time.Second, memcachedStore.config.timeout)
+	assert.Equal(t, 160, memcachedStore.config.replicas, "Zero fields should take the defaults")
+
+	require.NoError(t, memcachedStore.Put("key", "value", time.Minute))
+	val, err := memcachedStore.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+}
@@ -0,0 +1,87 @@
+package memcached
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// ring is a memcache.ServerSelector that spreads keys over servers with
+// consistent hashing. Each server is placed on a hash ring at several
+// points, and a key belongs to the first server point at or after the
+// key's hash. Adding or removing a server therefore only moves the keys
+// next to that server's points, instead of reshuffling every key.
+type ring struct {
+	points []uint32
+	addrs  map[uint32]net.Addr
+	all    []net.Addr
+}
+
+// newRing builds a ring over servers, placing each one at replicas points.
+func newRing(servers []string, replicas int) (*ring, error) {
+	r := &ring{addrs: make(map[uint32]net.Addr, len(servers)*replicas)}
+
+	for _, server := range servers {
+		addr, err := resolve(server)
+		if err != nil {
+			return nil, err
+		}
+		r.all = append(r.all, addr)
+
+		for i := 0; i < replicas; i++ {
+			point := hash(server + "-" + strconv.Itoa(i))
+			if _, taken := r.addrs[point]; taken {
+				continue
+			}
+
+			r.addrs[point] = addr
+			r.points = append(r.points, point)
+		}
+	}
+
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r, nil
+}
+
+func (r *ring) PickServer(key string) (net.Addr, error) {
+	if len(r.points) == 0 {
+		return nil, memcache.ErrNoServers
+	}
+
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.addrs[r.points[i]], nil
+}
+
+func (r *ring) Each(fn func(net.Addr) error) error {
+	for _, addr := range r.all {
+		if err := fn(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hash maps s to a point on the ring.
+func hash(s string) uint32 {
+	sum := md5.Sum([]byte(s))
+	return binary.LittleEndian.Uint32(sum[:4])
+}
+
+// resolve parses a server address, which is either host:port or the path
+// of a unix socket.
+func resolve(server string) (net.Addr, error) {
+	if strings.Contains(server, "/") {
+		return net.ResolveUnixAddr("unix", server)
+	}
+	return net.ResolveTCPAddr("tcp", server)
+}
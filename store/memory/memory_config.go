@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"errors"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+// Config configures a memory store created with New. The zero value is an
+// unbounded store without a janitor.
+type Config struct {
+	// MaxItems limits the number of items. Zero means no limit.
+	MaxItems int
+
+	// MaxBytes limits the total size of the items, as measured by
+	// SizeEstimator. Zero means no limit.
+	MaxBytes int64
+
+	// SizeEstimator measures items for MaxBytes. Nil counts the bytes of
+	// keys and of string and byte slice values.
+	SizeEstimator SizeEstimator
+
+	// EvictionPolicy selects which items are evicted when the store is
+	// full. The zero value is LRU.
+	EvictionPolicy EvictionPolicy
+
+	// OnEviction is called with every item evicted to stay within the
+	// limits.
+	OnEviction func(key string, data any)
+
+	// CleanupInterval is how often expired items are removed. Zero keeps
+	// them until FlushExpired is called.
+	CleanupInterval time.Duration
+
+	// Codec serializes values. Nil stores values as they are.
+	Codec store.Codec
+}
+
+// New returns a memory store configured by cfg, or the field errors of an
+// invalid cfg. Pass the store to cachey.NewFromStore, which initializes it.
+func New(cfg Config) (*MemoryStore, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	s := NewMemoryStore().(*MemoryStore)
+	s.config.maxItems = cfg.MaxItems
+	s.config.maxBytes = cfg.MaxBytes
+	s.config.policy = cfg.EvictionPolicy
+	s.config.onEviction = cfg.OnEviction
+	s.config.cleanupInterval = cfg.CleanupInterval
+	s.codec = cfg.Codec
+
+	if cfg.SizeEstimator != nil {
+		s.config.sizeEstimator = cfg.SizeEstimator
+	}
+
+	return s, nil
+}
+
+func (cfg Config) validate() error {
+	var errs []error
+	invalid := func(field, reason string) {
+		errs = append(errs, &store.FieldError{Store: "memory", Field: field, Reason: reason})
+	}
+
+	if cfg.MaxItems < 0 {
+		invalid("MaxItems", "must not be negative")
+	}
+
+	if cfg.MaxBytes < 0 {
+		invalid("MaxBytes", "must not be negative")
+	}
+
+	if cfg.EvictionPolicy != LRU && cfg.EvictionPolicy != LFU && cfg.EvictionPolicy != WTinyLFU {
+		invalid("EvictionPolicy", "unknown policy")
+	}
+
+	if cfg.CleanupInterval < 0 {
+		invalid("CleanupInterval", "must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
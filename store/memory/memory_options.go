@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+// WithMaxItems limits the number of items in the store. When a new item
+// takes the store over the limit, items are evicted according to the
+// eviction policy. Zero means no limit.
+func WithMaxItems(maxItems int) store.Option {
+	return func(s store.Store) error {
+		memoryStore, ok := s.(*MemoryStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for memory options")
+		}
+
+		if maxItems < 0 {
+			return fmt.Errorf("memory store: max items must not be negative")
+		}
+
+		memoryStore.config.maxItems = maxItems
+		return nil
+	}
+}
+
+// WithMaxBytes limits the total size of the items in the store, as
+// measured by the size estimator. Items larger than the limit are
+// rejected. Zero means no limit.
+func WithMaxBytes(maxBytes int64) store.Option {
+	return func(s store.Store) error {
+		memoryStore, ok := s.(*MemoryStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for memory options")
+		}
+
+		if maxBytes < 0 {
+			return fmt.Errorf("memory store: max bytes must not be negative")
+		}
+
+		memoryStore.config.maxBytes = maxBytes
+		return nil
+	}
+}
+
+// WithSizeEstimator sets how item sizes are measured for WithMaxBytes. The
+// default counts the bytes of keys and of string and byte slice values,
+// which is exact when a codec is set.
+func WithSizeEstimator(estimator SizeEstimator) store.Option {
+	return func(s store.Store) error {
+		memoryStore, ok := s.(*MemoryStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for memory options")
+		}
+
+		if estimator == nil {
+			return fmt.Errorf("memory store: size estimator must not be nil")
+		}
+
+		memoryStore.config.sizeEstimator = estimator
+		return nil
+	}
+}
+
+// WithEvictionPolicy selects which items are evicted when the store is
+// full. The default is LRU.
+func WithEvictionPolicy(policy EvictionPolicy) store.Option {
+	return func(s store.Store) error {
+		memoryStore, ok := s.(*MemoryStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for memory options")
+		}
+
+		if policy != LRU && policy != LFU && policy != WTinyLFU {
+			return fmt.Errorf("memory store: unknown eviction policy %d", policy)
+		}
+
+		memoryStore.config.policy = policy
+		return nil
+	}
+}
+
+// WithEvictionCallback registers fn to be called with every item evicted
+// to keep the store within its limits. It is called after the item has
+// been removed, outside of any store locks.
+func WithEvictionCallback(fn func(key string, data any)) store.Option {
+	return func(s store.Store) error {
+		memoryStore, ok := s.(*MemoryStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for memory options")
+		}
+
+		memoryStore.config.onEviction = fn
+		return nil
+	}
+}
+
+// WithCleanupInterval starts a janitor that removes expired items at the
+// given interval until the store is closed. Without it, expired items are
+// skipped on read but stay in memory until FlushExpired is called.
+func WithCleanupInterval(interval time.Duration) store.Option {
+	return func(s store.Store) error {
+		memoryStore, ok := s.(*MemoryStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for memory options")
+		}
+
+		if interval < 0 {
+			return fmt.Errorf("memory store: cleanup interval must not be negative")
+		}
+
+		memoryStore.config.cleanupInterval = interval
+		return nil
+	}
+}
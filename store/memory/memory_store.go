@@ -1,56 +1,605 @@
 package memory
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/codemaestro64/cachey/store"
 	"github.com/jellydator/ttlcache/v3"
 )
 
+// SizeEstimator returns the approximate number of bytes an item occupies.
+type SizeEstimator func(key string, data any) int64
+
+type config struct {
+	maxItems      int   // Zero means no limit.
+	maxBytes      int64 // Zero means no limit.
+	sizeEstimator SizeEstimator
+	policy        EvictionPolicy
+	onEviction    func(key string, data any)
+
+	cleanupInterval time.Duration // Zero disables the janitor.
+}
+
 type MemoryStore struct {
-	store *ttlcache.Cache[string, any]
+	config *config
+	store  *ttlcache.Cache[string, any]
+	codec  store.Codec
+
+	writeMu sync.Mutex // Serializes writes, so counter updates never interleave with another write.
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// The fields below are only used by bounded stores.
+	boundMu   sync.Mutex // Guards policy, sizes and bytes, and keeps them in step with store.
+	policy    policy
+	sizes     map[string]int64
+	bytes     int64
+	evictions atomic.Uint64
 }
 
 func NewMemoryStore() store.Store {
+	defaultConfig := config{
+		sizeEstimator: defaultSizeEstimator,
+		policy:        LRU,
+	}
+
 	return &MemoryStore{
-		store: ttlcache.New[string, any](),
+		config: &defaultConfig,
+		store:  ttlcache.New[string, any](),
 	}
 }
 
 func (s *MemoryStore) Init() error {
+	if s.config == nil {
+		return errors.New("memory store: configuration is missing")
+	}
+
+	if s.bounded() {
+		s.initBounds()
+	}
+
+	if s.config.cleanupInterval > 0 {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.cleanup()
+	}
+
 	return nil
 }
 
+// Close stops the janitor.
+func (s *MemoryStore) Close() error {
+	s.closeOnce.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+			<-s.done
+		}
+	})
+
+	return nil
+}
+
+// Ping always succeeds, as the store lives in the process.
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// cleanup periodically removes expired items until the store is closed.
+func (s *MemoryStore) cleanup() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.FlushExpired()
+		}
+	}
+}
+
+// initBounds sets up the accounting used to enforce capacity limits.
+func (s *MemoryStore) initBounds() {
+	s.policy = newPolicy(s.config.policy, s.config.maxItems)
+	s.sizes = make(map[string]int64)
+
+	// keep the accounting in step when expired items are removed
+	s.store.OnEviction(func(_ context.Context, reason ttlcache.EvictionReason, item *ttlcache.Item[string, any]) {
+		if reason != ttlcache.EvictionReasonExpired {
+			return
+		}
+
+		s.boundMu.Lock()
+		defer s.boundMu.Unlock()
+
+		// the key may have been set again since it expired
+		if !s.store.Has(item.Key()) {
+			s.forget(item.Key())
+		}
+	})
+}
+
+func (s *MemoryStore) SetCodec(codec store.Codec) {
+	s.codec = codec
+}
+
+func (s *MemoryStore) Codec() store.Codec {
+	return s.codec
+}
+
 func (s *MemoryStore) Has(key string) (bool, error) {
+	return s.HasCtx(context.Background(), key)
+}
+
+func (s *MemoryStore) HasCtx(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
 	return s.store.Has(key), nil
 }
 
 func (s *MemoryStore) Get(key string) (any, error) {
+	return s.GetCtx(context.Background(), key)
+}
+
+func (s *MemoryStore) GetCtx(ctx context.Context, key string) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	item := s.store.Get(key)
 	if item == nil || item.IsExpired() {
 		return nil, nil
 	}
 
-	return item.Value(), nil
+	if s.bounded() {
+		s.boundMu.Lock()
+		s.policy.access(key)
+		s.boundMu.Unlock()
+	}
+
+	return s.value(item.Value())
+}
+
+// value returns stored data as it was put. Counters are kept as plain
+// numbers, everything else goes through the codec.
+func (s *MemoryStore) value(data any) (any, error) {
+	if encoded, ok := data.([]byte); ok && s.codec != nil {
+		return store.Decode(s.codec, encoded)
+	}
+
+	return data, nil
 }
 
 func (s *MemoryStore) Put(key string, data any, duration time.Duration) error {
-	s.store.Set(key, data, duration)
+	return s.PutCtx(context.Background(), key, data, duration)
+}
+
+func (s *MemoryStore) PutCtx(ctx context.Context, key string, data any, duration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := store.Encode(s.codec, data)
+	if err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return s.set(key, data, duration)
+}
+
+func (s *MemoryStore) GetMany(ctx context.Context, keys []string) (map[string]any, error) {
+	items := make(map[string]any, len(keys))
+	for _, key := range keys {
+		data, err := s.GetCtx(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if data != nil {
+			items[key] = data
+		}
+	}
+
+	return items, nil
+}
+
+func (s *MemoryStore) PutMany(ctx context.Context, items map[string]any, duration time.Duration) error {
+	for key, data := range items {
+		if err := s.PutCtx(ctx, key, data, duration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteMany(ctx context.Context, keys []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		s.delete(key)
+	}
 
 	return nil
 }
+
+func (s *MemoryStore) PutIfAbsent(ctx context.Context, key string, data any, duration time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	data, err := store.Encode(s.codec, data)
+	if err != nil {
+		return false, err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if !s.bounded() {
+		_, found := s.store.GetOrSet(key, data, ttlcache.WithTTL[string, any](duration))
+		return !found, nil
+	}
+
+	if err := s.checkSize(key, data); err != nil {
+		return false, err
+	}
+
+	s.boundMu.Lock()
+	if s.store.Has(key) {
+		s.boundMu.Unlock()
+		return false, nil
+	}
+	evicted := s.setLocked(key, data, duration)
+	s.boundMu.Unlock()
+
+	s.notify(evicted)
+	return true, nil
+}
+
+func (s *MemoryStore) Increment(ctx context.Context, key string, by int64, duration time.Duration) (int64, error) {
+	var result int64
+	err := s.updateCounter(ctx, key, duration, func(current any) (any, error) {
+		value, err := toInt64(current)
+		if err != nil {
+			return nil, err
+		}
+
+		result = value + by
+		return result, nil
+	})
+
+	return result, err
+}
+
+func (s *MemoryStore) IncrementFloat(ctx context.Context, key string, by float64, duration time.Duration) (float64, error) {
+	var result float64
+	err := s.updateCounter(ctx, key, duration, func(current any) (any, error) {
+		value, err := toFloat64(current)
+		if err != nil {
+			return nil, err
+		}
+
+		result = value + by
+		return result, nil
+	})
+
+	return result, err
+}
+
+// updateCounter replaces the value under key with the result of update,
+// keeping the remaining lifetime of an existing item. Missing keys are
+// passed to update as nil and stored with the given duration.
+func (s *MemoryStore) updateCounter(ctx context.Context, key string, duration time.Duration, update func(current any) (any, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	var current any
+	item := s.store.Get(key, ttlcache.WithDisableTouchOnHit[string, any]())
+	if item != nil && !item.IsExpired() {
+		current = item.Value()
+		if item.TTL() > 0 {
+			duration = time.Until(item.ExpiresAt())
+			if duration <= 0 {
+				current = nil
+			}
+		} else {
+			duration = ttlcache.NoTTL
+		}
+	}
+
+	value, err := update(current)
+	if err != nil {
+		return fmt.Errorf("memory store: error updating counter `%s`: %w", key, err)
+	}
+
+	return s.set(key, value, duration)
+}
+
 func (s *MemoryStore) Delete(key string) error {
-	s.store.Delete(key)
+	return s.DeleteCtx(context.Background(), key)
+}
+
+func (s *MemoryStore) DeleteCtx(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.delete(key)
 
 	return nil
 }
 
 func (s *MemoryStore) Flush() error {
+	return s.FlushCtx(context.Background())
+}
+
+func (s *MemoryStore) FlushCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if !s.bounded() {
+		s.store.DeleteAll()
+		return nil
+	}
+
+	s.boundMu.Lock()
+	defer s.boundMu.Unlock()
+
 	s.store.DeleteAll()
+	s.policy = newPolicy(s.config.policy, s.config.maxItems)
+	s.sizes = make(map[string]int64)
+	s.bytes = 0
+
+	return nil
+}
+
+func (s *MemoryStore) FlushPrefix(ctx context.Context, prefix string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for _, key := range s.store.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			s.delete(key)
+		}
+	}
 
 	return nil
 }
 
 func (s *MemoryStore) FlushExpired() {
+	if !s.bounded() {
+		s.store.DeleteExpired()
+		return
+	}
+
+	s.boundMu.Lock()
+	defer s.boundMu.Unlock()
+
 	s.store.DeleteExpired()
+	for key := range s.sizes {
+		if !s.store.Has(key) {
+			s.forget(key)
+		}
+	}
+}
+
+// Evictions returns the number of items evicted to keep the store within
+// its limits. Expired and deleted items are not counted.
+func (s *MemoryStore) Evictions() uint64 {
+	return s.evictions.Load()
+}
+
+// bounded reports whether the store has a capacity limit.
+func (s *MemoryStore) bounded() bool {
+	return s.config.maxItems > 0 || s.config.maxBytes > 0
+}
+
+// set stores data under key, evicting other items if that takes the store
+// over its limits.
+func (s *MemoryStore) set(key string, data any, duration time.Duration) error {
+	if !s.bounded() {
+		s.store.Set(key, data, duration)
+		return nil
+	}
+
+	if err := s.checkSize(key, data); err != nil {
+		return err
+	}
+
+	s.boundMu.Lock()
+	evicted := s.setLocked(key, data, duration)
+	s.boundMu.Unlock()
+
+	s.notify(evicted)
+	return nil
+}
+
+// checkSize rejects items that could never fit in the store.
+func (s *MemoryStore) checkSize(key string, data any) error {
+	size := s.config.sizeEstimator(key, data)
+	if s.config.maxBytes > 0 && size > s.config.maxBytes {
+		return fmt.Errorf("memory store: item `%s` of %d bytes exceeds the %d byte limit", key, size, s.config.maxBytes)
+	}
+	return nil
+}
+
+// setLocked is set for bounded stores. It must be called with boundMu held
+// and returns the evicted items.
+func (s *MemoryStore) setLocked(key string, data any, duration time.Duration) []*ttlcache.Item[string, any] {
+	s.store.Set(key, data, duration)
+
+	if size, ok := s.sizes[key]; ok {
+		s.bytes -= size
+		s.policy.access(key)
+	} else {
+		s.policy.add(key)
+	}
+
+	size := s.config.sizeEstimator(key, data)
+	s.sizes[key] = size
+	s.bytes += size
+
+	var evicted []*ttlcache.Item[string, any]
+	for s.overLimit() {
+		victim, ok := s.policy.victim()
+		if !ok {
+			break
+		}
+
+		if item, found := s.store.GetAndDelete(victim, ttlcache.WithDisableTouchOnHit[string, any]()); found {
+			evicted = append(evicted, item)
+		}
+		s.forget(victim)
+	}
+
+	return evicted
+}
+
+func (s *MemoryStore) overLimit() bool {
+	return (s.config.maxItems > 0 && len(s.sizes) > s.config.maxItems) ||
+		(s.config.maxBytes > 0 && s.bytes > s.config.maxBytes)
+}
+
+// delete removes key from the store and from the accounting of bounded
+// stores.
+func (s *MemoryStore) delete(key string) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if !s.bounded() {
+		s.store.Delete(key)
+		return
+	}
+
+	s.boundMu.Lock()
+	defer s.boundMu.Unlock()
+
+	s.store.Delete(key)
+	s.forget(key)
+}
+
+// forget drops key from the accounting. It must be called with boundMu held.
+func (s *MemoryStore) forget(key string) {
+	size, ok := s.sizes[key]
+	if !ok {
+		return
+	}
+
+	s.bytes -= size
+	delete(s.sizes, key)
+	s.policy.remove(key)
+}
+
+// notify counts evicted items and reports them to the eviction callback.
+func (s *MemoryStore) notify(evicted []*ttlcache.Item[string, any]) {
+	if len(evicted) == 0 {
+		return
+	}
+
+	s.evictions.Add(uint64(len(evicted)))
+
+	if s.config.onEviction == nil {
+		return
+	}
+
+	for _, item := range evicted {
+		data, err := s.value(item.Value())
+		if err != nil {
+			data = item.Value()
+		}
+		s.config.onEviction(item.Key(), data)
+	}
+}
+
+// defaultSizeEstimator counts the bytes of keys and of string and byte
+// slice values, which covers every value when a codec is set. Other values
+// are counted as 16 bytes.
+func defaultSizeEstimator(key string, data any) int64 {
+	switch v := data.(type) {
+	case []byte:
+		return int64(len(key) + len(v))
+	case string:
+		return int64(len(key) + len(v))
+	default:
+		return int64(len(key)) + 16
+	}
+}
+
+// toInt64 converts a stored counter value to an int64. Nil values are zero.
+func toInt64(value any) (int64, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return int64(v), nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("value of type %T is not an integer", value)
+	}
+}
+
+// toFloat64 converts a stored counter value to a float64. Nil values are zero.
+func toFloat64(value any) (float64, error) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	default:
+		i, err := toInt64(value)
+		if err != nil {
+			return 0, fmt.Errorf("value of type %T is not a number", value)
+		}
+		return float64(i), nil
+	}
 }
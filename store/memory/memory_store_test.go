@@ -1,9 +1,15 @@
 package memory
 
 import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/codemaestro64/cachey/store"
+	"github.com/jellydator/ttlcache/v3"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -43,6 +49,105 @@ func TestMemoryStore_Has(t *testing.T) {
 	assert.Equal(t, false, exists)
 }
 
+func TestMemoryStore_PutIfAbsent(t *testing.T) {
+	store := NewMemoryStore().(*MemoryStore)
+
+	added, err := store.PutIfAbsent(context.Background(), "key", "value", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, true, added)
+
+	added, err = store.PutIfAbsent(context.Background(), "key", "other", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, false, added)
+
+	cachedValue, _ := store.Get("key")
+	assert.Equal(t, "value", cachedValue)
+
+	// expired keys count as absent
+	time.Sleep(2 * time.Second)
+	added, err = store.PutIfAbsent(context.Background(), "key", "other", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, true, added)
+}
+
+func TestMemoryStore_Increment(t *testing.T) {
+	store := NewMemoryStore().(*MemoryStore)
+	ctx := context.Background()
+
+	val, err := store.Increment(ctx, "counter", 2, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), val)
+
+	// the initial TTL is kept, not reset, by later increments
+	time.Sleep(600 * time.Millisecond)
+	val, err = store.Increment(ctx, "counter", 3, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), val)
+
+	time.Sleep(600 * time.Millisecond)
+	exists, _ := store.Has("counter")
+	assert.Equal(t, false, exists)
+
+	// non-numeric values are rejected
+	store.Put("text", "abc", time.Minute)
+	_, err = store.Increment(ctx, "text", 1, time.Minute)
+	assert.Error(t, err)
+
+	floatVal, err := store.IncrementFloat(ctx, "float", 0.5, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, floatVal)
+}
+
+func TestMemoryStore_IncrementWithPut(t *testing.T) {
+	store := NewMemoryStore().(*MemoryStore)
+	ctx := context.Background()
+
+	for round := 0; round < 50; round++ {
+		key := "counter" + strconv.Itoa(round)
+		start := make(chan struct{})
+		results := make(chan int64, 100)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+
+				val, err := store.Increment(ctx, key, 1, time.Minute)
+				assert.NoError(t, err)
+				results <- val
+			}()
+
+			if i == 50 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+
+					assert.NoError(t, store.Put(key, int64(1000), time.Minute))
+				}()
+			}
+		}
+
+		close(start)
+		wg.Wait()
+		close(results)
+
+		// every increment applied after the Put must be kept
+		var after int64
+		for val := range results {
+			if val > 1000 {
+				after++
+			}
+		}
+
+		val, err := store.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, 1000+after, val)
+	}
+}
+
 func TestMemoryStore_Delete(t *testing.T) {
 	store := NewMemoryStore()
 	key := "testKey"
@@ -72,3 +177,237 @@ func TestMemoryStore_Flush(t *testing.T) {
 	assert.Equal(t, false, has1)
 	assert.Equal(t, false, has2)
 }
+
+func TestMemoryStore_ContextCancelled(t *testing.T) {
+	store := NewMemoryStore().(*MemoryStore)
+	store.Put("key", "value", time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := store.GetCtx(ctx, "key")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	err = store.PutCtx(ctx, "key", "other", time.Minute)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	cachedValue, _ := store.Get("key")
+	assert.Equal(t, "value", cachedValue)
+}
+
+func TestMemoryStore_WithCodec(t *testing.T) {
+	memoryStore := NewMemoryStore()
+	err := store.WithCodec(store.JSONCodec{})(memoryStore)
+	assert.NoError(t, err)
+
+	value := map[string]any{"name": "widget"}
+	memoryStore.Put("key", value, time.Minute)
+
+	cachedValue, err := memoryStore.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, value, cachedValue)
+
+	// the stored copy is independent of the caller's map
+	value["name"] = "changed"
+	cachedValue, _ = memoryStore.Get("key")
+	assert.Equal(t, map[string]any{"name": "widget"}, cachedValue)
+}
+
+func TestMemoryStore_FlushPrefix(t *testing.T) {
+	store := NewMemoryStore().(*MemoryStore)
+	store.Put("svc-a:key1", "value1", time.Minute)
+	store.Put("svc-a:key2", "value2", time.Minute)
+	store.Put("svc-b:key1", "value1", time.Minute)
+
+	err := store.FlushPrefix(context.Background(), "svc-a:")
+	assert.NoError(t, err)
+
+	has1, _ := store.Has("svc-a:key1")
+	has2, _ := store.Has("svc-a:key2")
+	has3, _ := store.Has("svc-b:key1")
+	assert.Equal(t, false, has1)
+	assert.Equal(t, false, has2)
+	assert.Equal(t, true, has3)
+}
+
+func newBoundedStore(t *testing.T, options ...store.Option) *MemoryStore {
+	memoryStore := NewMemoryStore().(*MemoryStore)
+	for _, option := range options {
+		assert.NoError(t, option(memoryStore))
+	}
+	assert.NoError(t, memoryStore.Init())
+	return memoryStore
+}
+
+func TestMemoryStore_MaxItems(t *testing.T) {
+	var evicted []string
+	memoryStore := newBoundedStore(t,
+		WithMaxItems(2),
+		WithEvictionCallback(func(key string, data any) { evicted = append(evicted, key) }),
+	)
+
+	memoryStore.Put("a", "1", time.Minute)
+	memoryStore.Put("b", "2", time.Minute)
+	memoryStore.Get("a")
+	memoryStore.Put("c", "3", time.Minute)
+
+	hasA, _ := memoryStore.Has("a")
+	hasB, _ := memoryStore.Has("b")
+	hasC, _ := memoryStore.Has("c")
+	assert.Equal(t, true, hasA)
+	assert.Equal(t, false, hasB, "Least recently used item should be evicted")
+	assert.Equal(t, true, hasC)
+
+	assert.Equal(t, []string{"b"}, evicted)
+	assert.Equal(t, uint64(1), memoryStore.Evictions())
+
+	// overwriting an existing key does not evict
+	memoryStore.Put("a", "4", time.Minute)
+	assert.Equal(t, uint64(1), memoryStore.Evictions())
+}
+
+func TestMemoryStore_MaxBytes(t *testing.T) {
+	memoryStore := newBoundedStore(t,
+		WithMaxBytes(10),
+		WithSizeEstimator(func(key string, data any) int64 { return int64(len(data.(string))) }),
+	)
+
+	memoryStore.Put("a", "aaaa", time.Minute)
+	memoryStore.Put("b", "bbbb", time.Minute)
+	memoryStore.Put("c", "cccc", time.Minute)
+
+	hasA, _ := memoryStore.Has("a")
+	assert.Equal(t, false, hasA, "Oldest item should be evicted to make room")
+	assert.Equal(t, int64(8), memoryStore.bytes)
+
+	err := memoryStore.Put("d", "ddddddddddd", time.Minute)
+	assert.Error(t, err, "Items larger than the limit should be rejected")
+
+	memoryStore.Delete("b")
+	assert.Equal(t, int64(4), memoryStore.bytes)
+
+	memoryStore.Flush()
+	assert.Equal(t, int64(0), memoryStore.bytes)
+}
+
+func TestMemoryStore_LFU(t *testing.T) {
+	memoryStore := newBoundedStore(t, WithMaxItems(2), WithEvictionPolicy(LFU))
+
+	memoryStore.Put("a", "1", time.Minute)
+	memoryStore.Put("b", "2", time.Minute)
+	memoryStore.Get("a")
+	memoryStore.Get("a")
+	memoryStore.Get("b")
+	memoryStore.Put("c", "3", time.Minute)
+
+	hasA, _ := memoryStore.Has("a")
+	hasB, _ := memoryStore.Has("b")
+	assert.Equal(t, true, hasA)
+	assert.Equal(t, false, hasB, "Least frequently used item should be evicted")
+}
+
+func TestMemoryStore_WTinyLFU(t *testing.T) {
+	memoryStore := newBoundedStore(t, WithMaxItems(100), WithEvictionPolicy(WTinyLFU))
+
+	// a working set of popular keys
+	for i := 0; i < 50; i++ {
+		key := "hot" + strconv.Itoa(i)
+		memoryStore.Put(key, "value", time.Minute)
+		for j := 0; j < 5; j++ {
+			memoryStore.Get(key)
+		}
+	}
+
+	// a scan of keys that are never read again
+	for i := 0; i < 1000; i++ {
+		memoryStore.Put("scan"+strconv.Itoa(i), "value", time.Minute)
+	}
+
+	kept := 0
+	for i := 0; i < 50; i++ {
+		if has, _ := memoryStore.Has("hot" + strconv.Itoa(i)); has {
+			kept++
+		}
+	}
+	assert.GreaterOrEqual(t, kept, 45, "Popular keys should survive a scan")
+	assert.Equal(t, 100, memoryStore.store.Len())
+}
+
+func TestMemoryStore_BoundedFlushExpired(t *testing.T) {
+	memoryStore := newBoundedStore(t, WithMaxItems(10))
+
+	memoryStore.Put("expiring", "value", 10*time.Millisecond)
+	memoryStore.Put("forever", "value", -1)
+	time.Sleep(50 * time.Millisecond)
+
+	memoryStore.FlushExpired()
+	assert.Len(t, memoryStore.sizes, 1, "Expired items should leave the accounting")
+}
+
+func TestMemoryStore_CleanupInterval(t *testing.T) {
+	memoryStore := newBoundedStore(t, WithMaxItems(10), WithCleanupInterval(10*time.Millisecond))
+	defer memoryStore.Close()
+
+	memoryStore.Put("expiring", "value", 10*time.Millisecond)
+	memoryStore.Put("forever", "value", -1)
+
+	// Len already leaves out expired items, so wait on the accounting,
+	// which only changes once the janitor has removed them
+	assert.Eventually(t, func() bool {
+		memoryStore.boundMu.Lock()
+		defer memoryStore.boundMu.Unlock()
+		return len(memoryStore.sizes) == 1
+	}, time.Second, 10*time.Millisecond, "Expired items should be removed without FlushExpired")
+
+	assert.NoError(t, memoryStore.Close())
+	assert.NoError(t, memoryStore.Close(), "Close should be safe to call twice")
+}
+
+func TestMemoryStore_CleanupIntervalUnbounded(t *testing.T) {
+	memoryStore := NewMemoryStore().(*MemoryStore)
+	assert.NoError(t, WithCleanupInterval(10*time.Millisecond)(memoryStore))
+	assert.NoError(t, memoryStore.Init())
+	defer memoryStore.Close()
+
+	// Len and Get already hide expired items, so watch for the janitor
+	// removing them from the underlying cache
+	var expired atomic.Int32
+	memoryStore.store.OnEviction(func(_ context.Context, reason ttlcache.EvictionReason, _ *ttlcache.Item[string, any]) {
+		if reason == ttlcache.EvictionReasonExpired {
+			expired.Add(1)
+		}
+	})
+
+	memoryStore.Put("expiring", "value", 10*time.Millisecond)
+	memoryStore.Put("forever", "value", -1)
+
+	assert.Eventually(t, func() bool {
+		return expired.Load() == 1
+	}, time.Second, 10*time.Millisecond, "Expired items should be removed without FlushExpired")
+
+	has, err := memoryStore.Has("forever")
+	assert.NoError(t, err)
+	assert.True(t, has)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{MaxItems: -1, EvictionPolicy: EvictionPolicy(42), CleanupInterval: -time.Second})
+	var fields []string
+	for _, fieldError := range store.FieldErrors(err) {
+		fields = append(fields, fieldError.Field)
+	}
+	assert.Equal(t, []string{"MaxItems", "EvictionPolicy", "CleanupInterval"}, fields)
+
+	memoryStore, err := New(Config{MaxItems: 2, EvictionPolicy: LFU, Codec: store.JSONCodec{}})
+	assert.NoError(t, err)
+	assert.NoError(t, memoryStore.Init())
+	defer memoryStore.Close()
+
+	assert.Equal(t, LFU, memoryStore.config.policy)
+	assert.NotNil(t, memoryStore.config.sizeEstimator, "The default size estimator should be kept")
+
+	for _, key := range []string{"a", "b", "c"} {
+		assert.NoError(t, memoryStore.Put(key, "value", time.Minute))
+	}
+	assert.Equal(t, 2, memoryStore.store.Len())
+}
@@ -0,0 +1,340 @@
+package memory
+
+import (
+	"container/heap"
+	"container/list"
+	"hash/maphash"
+)
+
+// EvictionPolicy selects which items a bounded memory store evicts when it
+// is full.
+type EvictionPolicy int
+
+const (
+	// LRU evicts the least recently used item.
+	LRU EvictionPolicy = iota
+
+	// LFU evicts the least frequently used item, breaking ties by recency.
+	// The item added or used most recently is never evicted, so that new
+	// items get a chance to be used.
+	LFU
+
+	// WTinyLFU admits new items through a small LRU window and only lets
+	// them displace older items that have been used less often, as
+	// estimated by a frequency sketch. It resists scans and one-off keys
+	// better than LRU.
+	WTinyLFU
+)
+
+// policy tracks the keys of a bounded memory store and picks eviction
+// victims. Implementations are not safe for concurrent use.
+type policy interface {
+	// add records a new key.
+	add(key string)
+
+	// access records a read or an overwrite of an existing key.
+	access(key string)
+
+	// remove forgets a key.
+	remove(key string)
+
+	// victim returns the key to evict next, or false if there are no keys.
+	// The key stays tracked until it is removed.
+	victim() (string, bool)
+}
+
+func newPolicy(p EvictionPolicy, capacity int) policy {
+	switch p {
+	case LFU:
+		return newLFU()
+	case WTinyLFU:
+		return newWTinyLFU(capacity)
+	default:
+		return newLRU()
+	}
+}
+
+// lru is a least recently used policy.
+type lru struct {
+	order *list.List // Front is most recently used.
+	items map[string]*list.Element
+}
+
+func newLRU() *lru {
+	return &lru{order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (p *lru) add(key string) {
+	p.items[key] = p.order.PushFront(key)
+}
+
+func (p *lru) access(key string) {
+	if elem, ok := p.items[key]; ok {
+		p.order.MoveToFront(elem)
+	}
+}
+
+func (p *lru) remove(key string) {
+	if elem, ok := p.items[key]; ok {
+		p.order.Remove(elem)
+		delete(p.items, key)
+	}
+}
+
+func (p *lru) victim() (string, bool) {
+	elem := p.order.Back()
+	if elem == nil {
+		return "", false
+	}
+	return elem.Value.(string), true
+}
+
+// lfu is a least frequently used policy backed by a min-heap of access
+// counts.
+type lfu struct {
+	heap  lfuHeap
+	items map[string]*lfuEntry
+	tick  uint64 // Increases on every add and access, to break ties by recency.
+}
+
+type lfuEntry struct {
+	key      string
+	count    uint64
+	lastUsed uint64
+	index    int
+}
+
+func newLFU() *lfu {
+	return &lfu{items: make(map[string]*lfuEntry)}
+}
+
+func (p *lfu) add(key string) {
+	p.tick++
+	entry := &lfuEntry{key: key, count: 1, lastUsed: p.tick}
+	p.items[key] = entry
+	heap.Push(&p.heap, entry)
+}
+
+func (p *lfu) access(key string) {
+	entry, ok := p.items[key]
+	if !ok {
+		return
+	}
+
+	p.tick++
+	entry.count++
+	entry.lastUsed = p.tick
+	heap.Fix(&p.heap, entry.index)
+}
+
+func (p *lfu) remove(key string) {
+	if entry, ok := p.items[key]; ok {
+		heap.Remove(&p.heap, entry.index)
+		delete(p.items, key)
+	}
+}
+
+func (p *lfu) victim() (string, bool) {
+	switch {
+	case len(p.heap) == 0:
+		return "", false
+	case len(p.heap) == 1 || p.heap[0].lastUsed != p.tick:
+		return p.heap[0].key, true
+	}
+
+	// the root was just added or used, so take the smaller of its children
+	next := 1
+	if len(p.heap) > 2 && p.heap.Less(2, 1) {
+		next = 2
+	}
+	return p.heap[next].key, true
+}
+
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+	return h[i].lastUsed < h[j].lastUsed
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	entry := x.(*lfuEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// Segments of the W-TinyLFU policy.
+const (
+	window = iota
+	probation
+	protected
+)
+
+// wTinyLFU is a W-TinyLFU policy. New keys enter an LRU window holding
+// about 1% of the keys, and move on to the probation segment of the main
+// segmented LRU. When a key must be evicted, the newest key on probation
+// competes with the oldest, and the one used less often according to a
+// count-min sketch is evicted. Keys read while on probation are promoted
+// to the protected segment, which holds up to 80% of the main keys.
+type wTinyLFU struct {
+	segments [3]*list.List
+	items    map[string]*list.Element
+	sketch   *sketch
+}
+
+type wTinyLFUEntry struct {
+	key     string
+	segment int
+}
+
+func newWTinyLFU(capacity int) *wTinyLFU {
+	p := &wTinyLFU{items: make(map[string]*list.Element), sketch: newSketch(capacity)}
+	for i := range p.segments {
+		p.segments[i] = list.New()
+	}
+	return p
+}
+
+func (p *wTinyLFU) add(key string) {
+	p.sketch.increment(key)
+	p.items[key] = p.segments[window].PushFront(&wTinyLFUEntry{key: key, segment: window})
+
+	for p.segments[window].Len() > max(1, len(p.items)/100) {
+		p.move(p.segments[window].Back(), probation)
+	}
+}
+
+func (p *wTinyLFU) access(key string) {
+	p.sketch.increment(key)
+
+	elem, ok := p.items[key]
+	if !ok {
+		return
+	}
+
+	entry := elem.Value.(*wTinyLFUEntry)
+	if entry.segment != probation {
+		p.segments[entry.segment].MoveToFront(elem)
+		return
+	}
+
+	p.move(elem, protected)
+
+	// demote the oldest protected keys once the segment is full
+	main := p.segments[probation].Len() + p.segments[protected].Len()
+	for p.segments[protected].Len() > max(1, main*8/10) {
+		p.move(p.segments[protected].Back(), probation)
+	}
+}
+
+func (p *wTinyLFU) remove(key string) {
+	if elem, ok := p.items[key]; ok {
+		p.segments[elem.Value.(*wTinyLFUEntry).segment].Remove(elem)
+		delete(p.items, key)
+	}
+}
+
+func (p *wTinyLFU) victim() (string, bool) {
+	probationList := p.segments[probation]
+	if probationList.Len() == 0 {
+		// fall back to whichever segment still has keys
+		for _, segment := range []int{protected, window} {
+			if elem := p.segments[segment].Back(); elem != nil {
+				return elem.Value.(*wTinyLFUEntry).key, true
+			}
+		}
+		return "", false
+	}
+
+	candidate := probationList.Front().Value.(*wTinyLFUEntry).key
+	victim := probationList.Back().Value.(*wTinyLFUEntry).key
+	if p.sketch.estimate(candidate) > p.sketch.estimate(victim) {
+		return victim, true
+	}
+	return candidate, true
+}
+
+// move moves elem to the front of segment.
+func (p *wTinyLFU) move(elem *list.Element, segment int) {
+	entry := elem.Value.(*wTinyLFUEntry)
+	p.segments[entry.segment].Remove(elem)
+	entry.segment = segment
+	p.items[entry.key] = p.segments[segment].PushFront(entry)
+}
+
+// sketchDepth is the number of rows in a count-min sketch.
+const sketchDepth = 4
+
+// sketch is a count-min sketch of key frequencies. Counters saturate at 15
+// and are halved periodically, so the estimates favour recent use.
+type sketch struct {
+	rows      [sketchDepth][]uint8
+	seeds     [sketchDepth]maphash.Seed
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func newSketch(capacity int) *sketch {
+	width := 1024
+	for width < capacity {
+		width <<= 1
+	}
+
+	s := &sketch{mask: uint64(width - 1), resetAt: width * 10}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+		s.seeds[i] = maphash.MakeSeed()
+	}
+	return s
+}
+
+func (s *sketch) increment(key string) {
+	for i := range s.rows {
+		counter := &s.rows[i][maphash.String(s.seeds[i], key)&s.mask]
+		if *counter < 15 {
+			*counter++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *sketch) estimate(key string) uint8 {
+	estimate := uint8(15)
+	for i := range s.rows {
+		estimate = min(estimate, s.rows[i][maphash.String(s.seeds[i], key)&s.mask])
+	}
+	return estimate
+}
+
+// reset halves every counter so that old popularity fades.
+func (s *sketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
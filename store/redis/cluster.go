@@ -0,0 +1,72 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// forEachShard calls fn with the client of every cluster master, or with
+// the store's client when it does not talk to a cluster. Commands without
+// keys, such as FLUSHDB and SCAN, only reach a single node otherwise.
+func (s *RedisStore) forEachShard(ctx context.Context, fn func(ctx context.Context, client redis.UniversalClient) error) error {
+	if s.cluster == nil {
+		return fn(ctx, s.store)
+	}
+
+	return s.cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		return fn(ctx, client)
+	})
+}
+
+// mget returns the values of keys, with nil for missing keys. A cluster
+// rejects multi-key commands whose keys hash to different slots, so there
+// each key is read with its own GET in a pipeline, which the client splits
+// across shards.
+func (s *RedisStore) mget(ctx context.Context, keys []string) ([]any, error) {
+	if s.cluster == nil {
+		return s.store.MGet(ctx, keys...).Result()
+	}
+
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := s.store.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, key)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	values := make([]any, len(keys))
+	for i, cmd := range cmds {
+		val, err := cmd.Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+		case err != nil:
+			return nil, err
+		default:
+			values[i] = val
+		}
+	}
+
+	return values, nil
+}
+
+// unlink removes keys through client, one UNLINK per key in a pipeline when
+// talking to a cluster. See mget.
+func (s *RedisStore) unlink(ctx context.Context, client redis.UniversalClient, keys []string) error {
+	if s.cluster == nil {
+		return client.Unlink(ctx, keys...).Err()
+	}
+
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Unlink(ctx, key)
+		}
+		return nil
+	})
+	return err
+}
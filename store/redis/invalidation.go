@@ -0,0 +1,110 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+// invalidationMessage is published on the invalidation channel whenever the
+// store changes a key. Source identifies the publishing store, so that
+// stores can ignore their own messages.
+type invalidationMessage struct {
+	Source string   `json:"source"`
+	Keys   []string `json:"keys,omitempty"`
+	Prefix string   `json:"prefix,omitempty"`
+	Flush  bool     `json:"flush,omitempty"`
+}
+
+// OnInvalidate registers fn to be called when another store publishes a
+// change on the invalidation channel. It has no effect unless the store was
+// configured with WithInvalidationChannel. Messages published while the
+// subscription is reconnecting are lost, so local copies should still
+// expire on their own.
+func (s *RedisStore) OnInvalidate(fn func(store.Invalidation)) {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+
+	s.handlers = append(s.handlers, fn)
+}
+
+// subscribe starts listening for invalidations published by other stores.
+func (s *RedisStore) subscribe() error {
+	sourceBytes := make([]byte, 16)
+	if _, err := rand.Read(sourceBytes); err != nil {
+		return fmt.Errorf("redis store: error generating source id: %w", err)
+	}
+	s.source = hex.EncodeToString(sourceBytes)
+
+	ctx, cancel := s.readContext(context.Background())
+	defer cancel()
+
+	s.pubsub = s.store.Subscribe(ctx, s.config.invalidationChannel)
+	if _, err := s.pubsub.Receive(ctx); err != nil {
+		s.pubsub.Close()
+		return fmt.Errorf("redis store: error subscribing to invalidation channel: %w", err)
+	}
+
+	s.subscribed = make(chan struct{})
+	go s.receive()
+
+	return nil
+}
+
+// receive dispatches invalidation messages until the subscription is closed.
+func (s *RedisStore) receive() {
+	defer close(s.subscribed)
+
+	for msg := range s.pubsub.Channel() {
+		var message invalidationMessage
+		if err := json.Unmarshal([]byte(msg.Payload), &message); err != nil || message.Source == s.source {
+			continue
+		}
+
+		inv := store.Invalidation{Keys: message.Keys, Prefix: message.Prefix, Flush: message.Flush}
+
+		s.handlersMu.Lock()
+		handlers := s.handlers
+		s.handlersMu.Unlock()
+
+		for _, handler := range handlers {
+			handler(inv)
+		}
+	}
+}
+
+// publish announces a change on the invalidation channel, if one is set.
+// The change itself is already committed, so failures are not returned to
+// the caller but reported to the handler set with
+// WithInvalidationErrorHandler.
+func (s *RedisStore) publish(ctx context.Context, inv store.Invalidation) {
+	if s.config.invalidationChannel == "" {
+		return
+	}
+
+	payload, err := json.Marshal(invalidationMessage{
+		Source: s.source,
+		Keys:   inv.Keys,
+		Prefix: inv.Prefix,
+		Flush:  inv.Flush,
+	})
+	if err != nil {
+		s.invalidationError(fmt.Errorf("redis store: error encoding invalidation: %w", err))
+		return
+	}
+
+	if err := s.store.Publish(ctx, s.config.invalidationChannel, payload).Err(); err != nil {
+		s.invalidationError(fmt.Errorf("redis store: error publishing invalidation: %w", err))
+	}
+}
+
+// invalidationError reports err to the invalidation error handler, if any.
+func (s *RedisStore) invalidationError(err error) {
+	if s.config.onInvalidationError != nil {
+		s.config.onInvalidationError(err)
+	}
+}
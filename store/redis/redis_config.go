@@ -0,0 +1,199 @@
+package redis
+
+import (
+	"crypto/tls"
+	"errors"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+	"github.com/redis/go-redis/v9"
+)
+
+// Config configures a redis store created with New. Zero fields take the
+// same defaults as NewRedisStore.
+type Config struct {
+	// Address is the host:port of a single server. Defaults to
+	// localhost:6379 unless URL, SentinelAddrs, ClusterAddrs or Client is
+	// set.
+	Address string
+
+	// URL configures the connection from a redis:// or rediss:// URL, as
+	// WithURL does. It cannot be combined with Address.
+	URL string
+
+	Username string
+	Password string
+	DB       int
+
+	// TLSConfig enables TLS when set.
+	TLSConfig *tls.Config
+
+	// PoolSize is the maximum number of connections per server. Zero uses
+	// the go-redis default.
+	PoolSize int
+
+	// MaxRetries is how often failed commands are retried. Zero retries
+	// up to 5 times; a negative value disables retries.
+	MaxRetries int
+
+	// DialTimeout bounds establishing a connection. Defaults to 5 seconds.
+	DialTimeout time.Duration
+
+	// ReadTimeout and WriteTimeout bound calls whose context has no
+	// deadline. They default to 10 seconds; a negative value leaves such
+	// calls unbounded.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// SentinelMasterName and SentinelAddrs connect through Redis Sentinel,
+	// as WithSentinel does.
+	SentinelMasterName string
+	SentinelAddrs      []string
+	SentinelUsername   string
+	SentinelPassword   string
+
+	// ClusterAddrs connects to a Redis Cluster through its seed nodes, as
+	// WithCluster does.
+	ClusterAddrs []string
+
+	// Client is used instead of connecting with the fields above. The store
+	// does not close it.
+	Client redis.UniversalClient
+
+	// LockTTL enables distributed locking, as WithDistributedLock does.
+	// Zero disables it.
+	LockTTL time.Duration
+
+	// LockRetryInterval is how often a waiting lock is retried. Defaults to
+	// 50 milliseconds.
+	LockRetryInterval time.Duration
+
+	// InvalidationChannel enables invalidation messages, as
+	// WithInvalidationChannel does.
+	InvalidationChannel string
+
+	// OnInvalidationError is called when an invalidation message cannot be
+	// published, as with WithInvalidationErrorHandler.
+	OnInvalidationError func(err error)
+
+	// Codec serializes values. Nil stores values as they are.
+	Codec store.Codec
+}
+
+// New returns a redis store configured by cfg, or the field errors of an
+// invalid cfg. Pass the store to cachey.NewFromStore, which initializes it
+// and connects.
+func New(cfg Config) (*RedisStore, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	s := NewRedisStore().(*RedisStore)
+	if cfg.URL != "" {
+		if err := WithURL(cfg.URL)(s); err != nil {
+			return nil, err
+		}
+	}
+
+	c := s.config
+	if cfg.Address != "" {
+		c.address = cfg.Address
+	}
+	if cfg.Username != "" {
+		c.username = cfg.Username
+	}
+	if cfg.Password != "" {
+		c.password = cfg.Password
+	}
+	if cfg.DB != 0 {
+		c.db = cfg.DB
+	}
+	if cfg.TLSConfig != nil {
+		c.tlsConfig = cfg.TLSConfig
+	}
+	if cfg.PoolSize != 0 {
+		c.poolSize = cfg.PoolSize
+	}
+	if cfg.MaxRetries != 0 {
+		c.maxRetries = max(cfg.MaxRetries, -1)
+	}
+	if cfg.DialTimeout != 0 {
+		c.dialTimeout = cfg.DialTimeout
+	}
+	if cfg.ReadTimeout != 0 {
+		c.readTimeout = cfg.ReadTimeout
+	}
+	if cfg.WriteTimeout != 0 {
+		c.writeTimeout = cfg.WriteTimeout
+	}
+	if cfg.LockRetryInterval != 0 {
+		c.lockRetryInterval = cfg.LockRetryInterval
+	}
+
+	c.masterName = cfg.SentinelMasterName
+	c.sentinelAddrs = cfg.SentinelAddrs
+	c.sentinelUsername = cfg.SentinelUsername
+	c.sentinelPassword = cfg.SentinelPassword
+	c.clusterAddrs = cfg.ClusterAddrs
+	c.client = cfg.Client
+	c.lockTTL = cfg.LockTTL
+	c.invalidationChannel = cfg.InvalidationChannel
+	c.onInvalidationError = cfg.OnInvalidationError
+	s.codec = cfg.Codec
+
+	return s, nil
+}
+
+func (cfg Config) validate() error {
+	var errs []error
+	invalid := func(field, reason string) {
+		errs = append(errs, &store.FieldError{Store: "redis", Field: field, Reason: reason})
+	}
+
+	if cfg.URL != "" {
+		if cfg.Address != "" {
+			invalid("URL", "cannot be combined with Address")
+		}
+		if _, err := redis.ParseURL(cfg.URL); err != nil {
+			invalid("URL", err.Error())
+		}
+	}
+
+	sentinel := cfg.SentinelMasterName != "" || len(cfg.SentinelAddrs) > 0
+	if sentinel && cfg.SentinelMasterName == "" {
+		invalid("SentinelMasterName", "is required with SentinelAddrs")
+	}
+	if sentinel && len(cfg.SentinelAddrs) == 0 {
+		invalid("SentinelAddrs", "is required with SentinelMasterName")
+	}
+
+	cluster := len(cfg.ClusterAddrs) > 0
+	if cluster && sentinel {
+		invalid("ClusterAddrs", "cannot be combined with Sentinel")
+	}
+	if cluster && cfg.DB != 0 {
+		invalid("DB", "a cluster only has database 0")
+	}
+
+	if cfg.Client != nil && (cfg.Address != "" || cfg.URL != "" || sentinel || cluster) {
+		invalid("Client", "cannot be combined with Address, URL, Sentinel or cluster settings")
+	}
+
+	if cfg.DB < 0 {
+		invalid("DB", "must not be negative")
+	}
+	if cfg.PoolSize < 0 {
+		invalid("PoolSize", "must not be negative")
+	}
+	if cfg.DialTimeout < 0 {
+		invalid("DialTimeout", "must not be negative")
+	}
+	if cfg.LockTTL < 0 {
+		invalid("LockTTL", "must not be negative")
+	}
+	if cfg.LockRetryInterval < 0 {
+		invalid("LockRetryInterval", "must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,225 @@
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dialect identifies the SQL flavour spoken by the database.
+type Dialect string
+
+// Supported dialects.
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+	MySQL    Dialect = "mysql"
+)
+
+// dialectFor guesses the dialect from a database/sql driver name.
+func dialectFor(driverName string) (Dialect, bool) {
+	switch driverName {
+	case "postgres", "pgx", "pgx/v5":
+		return Postgres, true
+	case "sqlite", "sqlite3":
+		return SQLite, true
+	case "mysql":
+		return MySQL, true
+	default:
+		return "", false
+	}
+}
+
+func (d Dialect) valid() bool {
+	return d == Postgres || d == SQLite || d == MySQL
+}
+
+// tableName matches table names, optionally qualified by a schema.
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// quote quotes an identifier, quoting each part of a qualified name.
+func (d Dialect) quote(name string) string {
+	q := `"`
+	if d == MySQL {
+		q = "`"
+	}
+
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = q + part + q
+	}
+	return strings.Join(parts, ".")
+}
+
+// rebind replaces ? placeholders with the dialect's placeholder syntax.
+func (d Dialect) rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+	return b.String()
+}
+
+// queries holds the statements used by the store, built once for a table
+// and dialect.
+type queries struct {
+	get         string
+	has         string
+	put         string
+	putIfAbsent string
+	delete      string
+	flush       string
+	flushPrefix string
+	prune       string
+	getMany     func(n int) string
+	deleteMany  func(n int) string
+
+	// increment and incrementFloat add to a counter, restarting it if it
+	// has expired; counter reads its value back.
+	increment      string
+	incrementFloat string
+	counter        string
+}
+
+func newQueries(d Dialect, table string) *queries {
+	t := d.quote(table)
+	key, value, expiresAt := d.quote("key"), d.quote("value"), d.quote("expires_at")
+	live := fmt.Sprintf("(%s = 0 OR %s > ?)", expiresAt, expiresAt)
+	insert := fmt.Sprintf("INSERT INTO %s (%s, %s, %s) VALUES (?, ?, ?)", t, key, value, expiresAt)
+
+	q := &queries{
+		get:         fmt.Sprintf("SELECT %s FROM %s WHERE %s = ? AND %s", value, t, key, live),
+		has:         fmt.Sprintf("SELECT 1 FROM %s WHERE %s = ? AND %s", t, key, live),
+		delete:      fmt.Sprintf("DELETE FROM %s WHERE %s = ?", t, key),
+		flush:       fmt.Sprintf("DELETE FROM %s", t),
+		flushPrefix: fmt.Sprintf("DELETE FROM %s WHERE %s LIKE ? ESCAPE '!'", t, key),
+		prune:       fmt.Sprintf("DELETE FROM %s WHERE %s <> 0 AND %s <= ?", t, expiresAt, expiresAt),
+		counter:     fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", value, t, key),
+	}
+
+	// counters are kept as decimal text, so the addition converts the
+	// stored value to a number and back
+	var addInt, addFloat string
+	switch d {
+	case Postgres:
+		addInt = fmt.Sprintf("convert_to((convert_from(%s.%s, 'UTF8')::BIGINT + ?)::TEXT, 'UTF8')", t, value)
+		addFloat = fmt.Sprintf("convert_to((convert_from(%s.%s, 'UTF8')::DOUBLE PRECISION + ?)::TEXT, 'UTF8')", t, value)
+	case SQLite:
+		addInt = fmt.Sprintf("CAST(CAST(%s.%s AS INTEGER) + ? AS BLOB)", t, value)
+		addFloat = fmt.Sprintf("CAST(CAST(%s.%s AS REAL) + ? AS BLOB)", t, value)
+	case MySQL:
+		addInt = fmt.Sprintf("CAST(CAST(%s AS SIGNED) + ? AS CHAR)", value)
+		addFloat = fmt.Sprintf("CAST(%s + ? AS CHAR)", value)
+	}
+
+	// Add only replaces rows that have expired, so it is an upsert whose
+	// update is conditional on the existing row's expiry.
+	if d == MySQL {
+		expired := fmt.Sprintf("%s <> 0 AND %s <= ?", expiresAt, expiresAt)
+		q.put = fmt.Sprintf("%s ON DUPLICATE KEY UPDATE %s = VALUES(%s), %s = VALUES(%s)",
+			insert, value, value, expiresAt, expiresAt)
+		// assignments run left to right, so value must be set before
+		// expires_at changes
+		q.putIfAbsent = fmt.Sprintf("%s ON DUPLICATE KEY UPDATE %s = IF(%s, VALUES(%s), %s), %s = IF(%s, VALUES(%s), %s)",
+			insert, value, expired, value, value, expiresAt, expired, expiresAt, expiresAt)
+		increment := func(add string) string {
+			return fmt.Sprintf("%s ON DUPLICATE KEY UPDATE %s = IF(%s, VALUES(%s), %s), %s = IF(%s, VALUES(%s), %s)",
+				insert, value, expired, value, add, expiresAt, expired, expiresAt, expiresAt)
+		}
+		q.increment, q.incrementFloat = increment(addInt), increment(addFloat)
+	} else {
+		q.put = fmt.Sprintf("%s ON CONFLICT (%s) DO UPDATE SET %s = excluded.%s, %s = excluded.%s",
+			insert, key, value, value, expiresAt, expiresAt)
+		q.putIfAbsent = fmt.Sprintf("%s ON CONFLICT (%s) DO UPDATE SET %s = excluded.%s, %s = excluded.%s WHERE %s.%s <> 0 AND %s.%s <= ?",
+			insert, key, value, value, expiresAt, expiresAt, t, expiresAt, t, expiresAt)
+		expired := fmt.Sprintf("%s.%s <> 0 AND %s.%s <= ?", t, expiresAt, t, expiresAt)
+		increment := func(add string) string {
+			return fmt.Sprintf("%s ON CONFLICT (%s) DO UPDATE SET %s = CASE WHEN %s THEN excluded.%s ELSE %s END, %s = CASE WHEN %s THEN excluded.%s ELSE %s.%s END",
+				insert, key, value, expired, value, add, expiresAt, expired, expiresAt, t, expiresAt)
+		}
+		q.increment, q.incrementFloat = increment(addInt), increment(addFloat)
+	}
+
+	q.getMany = func(n int) string {
+		return d.rebind(fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s IN (%s) AND %s", key, value, t, key, placeholders(n), live))
+	}
+	q.deleteMany = func(n int) string {
+		return d.rebind(fmt.Sprintf("DELETE FROM %s WHERE %s IN (%s)", t, key, placeholders(n)))
+	}
+
+	for _, query := range []*string{&q.get, &q.has, &q.put, &q.putIfAbsent, &q.increment, &q.incrementFloat, &q.counter, &q.delete, &q.flush, &q.flushPrefix, &q.prune} {
+		*query = d.rebind(*query)
+	}
+
+	return q
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// escapeLike escapes the LIKE wildcards in s using '!' as the escape
+// character.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// Migrate creates the cache table and its expiry index if they do not
+// already exist.
+func Migrate(ctx context.Context, db *dbsql.DB, dialect Dialect, table string) error {
+	if !dialect.valid() {
+		return fmt.Errorf("sql store: unsupported dialect `%s`", dialect)
+	}
+
+	if !tableName.MatchString(table) {
+		return fmt.Errorf("sql store: invalid table name `%s`", table)
+	}
+
+	t := dialect.quote(table)
+	key, value, expiresAt := dialect.quote("key"), dialect.quote("value"), dialect.quote("expires_at")
+	// indexes live in their table's schema, so the index name is never qualified
+	index := dialect.quote(strings.ReplaceAll(table, ".", "_") + "_expires_at_index")
+
+	var statements []string
+	switch dialect {
+	case Postgres:
+		statements = []string{
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s VARCHAR(255) PRIMARY KEY, %s BYTEA NOT NULL, %s BIGINT NOT NULL DEFAULT 0)", t, key, value, expiresAt),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", index, t, expiresAt),
+		}
+	case SQLite:
+		statements = []string{
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s TEXT PRIMARY KEY, %s BLOB NOT NULL, %s INTEGER NOT NULL DEFAULT 0)", t, key, value, expiresAt),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", index, t, expiresAt),
+		}
+	case MySQL:
+		// MySQL has no CREATE INDEX IF NOT EXISTS, so the index is part of
+		// the table definition. Keys are binary strings, as the default
+		// collations compare text case-insensitively and ignore trailing
+		// spaces, which would make distinct keys collide.
+		statements = []string{
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s VARBINARY(255) NOT NULL PRIMARY KEY, %s LONGBLOB NOT NULL, %s BIGINT NOT NULL DEFAULT 0, INDEX %s (%s))", t, key, value, expiresAt, index, expiresAt),
+		}
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("sql store: error migrating cache table: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package sql
+
+import (
+	dbsql "database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+// Config configures a sql store created with New. Either DB or DriverName
+// must be set; other zero fields take the same defaults as NewSQLStore.
+type Config struct {
+	// DB is an existing database handle, as with WithDB. The store does not
+	// close it, and MySQL connections must not enable clientFoundRows.
+	DB *dbsql.DB
+
+	// DriverName and DSN open a database when the store is initialized, as
+	// with WithDSN.
+	DriverName string
+	DSN        string
+
+	// Dialect selects the SQL dialect. It is required with DB and derived
+	// from DriverName otherwise.
+	Dialect Dialect
+
+	// Table is the cache table, optionally qualified by a schema. Defaults
+	// to "cache".
+	Table string
+
+	// AutoMigrate creates the table when the store is initialized.
+	AutoMigrate bool
+
+	// PruneInterval is how often expired rows are deleted. Defaults to a
+	// minute; a negative value disables the job.
+	PruneInterval time.Duration
+
+	// Codec serializes values. Defaults to store.GobCodec.
+	Codec store.Codec
+}
+
+// New returns a sql store configured by cfg, or the field errors of an
+// invalid cfg. Pass the store to cachey.NewFromStore, which initializes it
+// and connects.
+func New(cfg Config) (*SQLStore, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	s := NewSQLStore().(*SQLStore)
+	s.config.db = cfg.DB
+	s.config.driverName = cfg.DriverName
+	s.config.dsn = cfg.DSN
+	s.config.dialect = cfg.Dialect
+	s.config.autoMigrate = cfg.AutoMigrate
+
+	if cfg.Table != "" {
+		s.config.table = cfg.Table
+	}
+	if cfg.PruneInterval != 0 {
+		s.config.pruneInterval = max(cfg.PruneInterval, 0)
+	}
+	if cfg.Codec != nil {
+		s.codec = cfg.Codec
+	}
+
+	return s, nil
+}
+
+func (cfg Config) validate() error {
+	var errs []error
+	invalid := func(field, reason string) {
+		errs = append(errs, &store.FieldError{Store: "sql", Field: field, Reason: reason})
+	}
+
+	switch {
+	case cfg.DB == nil && cfg.DriverName == "":
+		invalid("DB", "either DB or DriverName is required")
+	case cfg.DB != nil && cfg.DriverName != "":
+		invalid("DriverName", "cannot be combined with DB")
+	}
+
+	if cfg.DriverName == "" && cfg.DSN != "" {
+		invalid("DriverName", "is required with DSN")
+	}
+
+	switch {
+	case cfg.Dialect != "" && !cfg.Dialect.valid():
+		invalid("Dialect", fmt.Sprintf("unsupported dialect `%s`", cfg.Dialect))
+	case cfg.Dialect == "" && cfg.DB != nil:
+		invalid("Dialect", "is required with DB")
+	case cfg.Dialect == "" && cfg.DriverName != "":
+		if _, ok := dialectFor(cfg.DriverName); !ok {
+			invalid("Dialect", fmt.Sprintf("cannot be derived from driver `%s`", cfg.DriverName))
+		}
+	}
+
+	if cfg.Table != "" && !tableName.MatchString(cfg.Table) {
+		invalid("Table", fmt.Sprintf("invalid table name `%s`", cfg.Table))
+	}
+
+	return errors.Join(errs...)
+}
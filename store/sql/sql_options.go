@@ -0,0 +1,103 @@
+package sql
+
+import (
+	dbsql "database/sql"
+	"fmt"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+// WithDB uses an existing database handle. The store does not close it.
+// MySQL connections must not enable clientFoundRows, as Add relies on
+// affected row counts.
+func WithDB(db *dbsql.DB, dialect Dialect) store.Option {
+	return func(s store.Store) error {
+		sqlStore, ok := s.(*SQLStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for sql options")
+		}
+
+		sqlStore.config.db = db
+		sqlStore.config.dialect = dialect
+		return nil
+	}
+}
+
+// WithDSN opens a database with the given database/sql driver when the
+// store is initialized. The dialect is derived from the driver name unless
+// set with WithDialect.
+func WithDSN(driverName, dsn string) store.Option {
+	return func(s store.Store) error {
+		sqlStore, ok := s.(*SQLStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for sql options")
+		}
+
+		sqlStore.config.driverName = driverName
+		sqlStore.config.dsn = dsn
+		return nil
+	}
+}
+
+func WithDialect(dialect Dialect) store.Option {
+	return func(s store.Store) error {
+		sqlStore, ok := s.(*SQLStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for sql options")
+		}
+
+		if !dialect.valid() {
+			return fmt.Errorf("sql store: unsupported dialect `%s`", dialect)
+		}
+
+		sqlStore.config.dialect = dialect
+		return nil
+	}
+}
+
+// WithTable sets the cache table name, optionally qualified by a schema.
+func WithTable(table string) store.Option {
+	return func(s store.Store) error {
+		sqlStore, ok := s.(*SQLStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for sql options")
+		}
+
+		if !tableName.MatchString(table) {
+			return fmt.Errorf("sql store: invalid table name `%s`", table)
+		}
+
+		sqlStore.config.table = table
+		return nil
+	}
+}
+
+// WithAutoMigrate creates the cache table when the store is initialized.
+// See Migrate.
+func WithAutoMigrate() store.Option {
+	return func(s store.Store) error {
+		sqlStore, ok := s.(*SQLStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for sql options")
+		}
+
+		sqlStore.config.autoMigrate = true
+		return nil
+	}
+}
+
+// WithPruneInterval sets how often expired rows are deleted in the
+// background. Zero disables the job; expired rows are then ignored on read
+// but kept until FlushExpired is called.
+func WithPruneInterval(interval time.Duration) store.Option {
+	return func(s store.Store) error {
+		sqlStore, ok := s.(*SQLStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for sql options")
+		}
+
+		sqlStore.config.pruneInterval = interval
+		return nil
+	}
+}
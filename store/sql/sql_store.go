@@ -0,0 +1,494 @@
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+type config struct {
+	db            *dbsql.DB
+	driverName    string
+	dsn           string
+	dialect       Dialect
+	table         string
+	autoMigrate   bool
+	pruneInterval time.Duration
+}
+
+// SQLStore keeps cache entries in a database table with key, value and
+// expires_at columns. Expiry times are unix milliseconds; zero means the
+// entry never expires.
+type SQLStore struct {
+	config  *config
+	db      *dbsql.DB
+	ownsDB  bool // Whether the store opened db and must close it.
+	queries *queries
+	codec   store.Codec
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewSQLStore() store.Store {
+	defaultConfig := config{
+		table:         "cache",
+		pruneInterval: time.Minute,
+	}
+
+	return &SQLStore{
+		config: &defaultConfig,
+		codec:  store.GobCodec{},
+	}
+}
+
+func (s *SQLStore) Init() error {
+	if s.config == nil {
+		return errors.New("sql store: configuration is missing")
+	}
+
+	if s.codec == nil {
+		return errors.New("sql store: a codec is required")
+	}
+
+	if !tableName.MatchString(s.config.table) {
+		return fmt.Errorf("sql store: invalid table name `%s`", s.config.table)
+	}
+
+	if s.config.dialect == "" {
+		dialect, ok := dialectFor(s.config.driverName)
+		if !ok {
+			return errors.New("sql store: a dialect is required")
+		}
+		s.config.dialect = dialect
+	}
+
+	if !s.config.dialect.valid() {
+		return fmt.Errorf("sql store: unsupported dialect `%s`", s.config.dialect)
+	}
+
+	if s.config.dialect == MySQL && clientFoundRows(s.config.dsn) {
+		return errors.New("sql store: the clientFoundRows DSN parameter is not supported")
+	}
+
+	switch {
+	case s.config.db != nil:
+		s.db = s.config.db
+	case s.config.driverName != "":
+		db, err := dbsql.Open(s.config.driverName, s.config.dsn)
+		if err != nil {
+			return fmt.Errorf("sql store: error opening database: %w", err)
+		}
+		s.db, s.ownsDB = db, true
+	default:
+		return errors.New("sql store: a database is required")
+	}
+
+	if err := s.db.Ping(); err != nil {
+		s.closeDB()
+		return fmt.Errorf("sql store: error connecting to database: %w", err)
+	}
+
+	if s.config.autoMigrate {
+		if err := Migrate(context.Background(), s.db, s.config.dialect, s.config.table); err != nil {
+			s.closeDB()
+			return err
+		}
+	}
+
+	s.queries = newQueries(s.config.dialect, s.config.table)
+
+	if s.config.pruneInterval > 0 {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.prune()
+	}
+
+	return nil
+}
+
+// Close stops the background pruning job. The database is closed only if
+// the store opened it.
+func (s *SQLStore) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+			<-s.done
+		}
+
+		err = s.closeDB()
+	})
+
+	return err
+}
+
+// Ping checks that the database connection is alive.
+func (s *SQLStore) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("sql store: error pinging database: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLStore) closeDB() error {
+	if !s.ownsDB || s.db == nil {
+		return nil
+	}
+
+	return s.db.Close()
+}
+
+func (s *SQLStore) SetCodec(codec store.Codec) {
+	s.codec = codec
+}
+
+func (s *SQLStore) Codec() store.Codec {
+	return s.codec
+}
+
+func (s *SQLStore) Has(key string) (bool, error) {
+	return s.HasCtx(context.Background(), key)
+}
+
+func (s *SQLStore) HasCtx(ctx context.Context, key string) (bool, error) {
+	var found int
+	err := s.db.QueryRowContext(ctx, s.queries.has, key, now()).Scan(&found)
+	if errors.Is(err, dbsql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("sql store: error checking key: %w", err)
+	}
+
+	return true, nil
+}
+
+func (s *SQLStore) Get(key string) (any, error) {
+	return s.GetCtx(context.Background(), key)
+}
+
+func (s *SQLStore) GetCtx(ctx context.Context, key string) (any, error) {
+	var value []byte
+	err := s.db.QueryRowContext(ctx, s.queries.get, key, now()).Scan(&value)
+	if errors.Is(err, dbsql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sql store: error getting cache data: %w", err)
+	}
+
+	return s.decode(value)
+}
+
+func (s *SQLStore) Put(key string, data any, duration time.Duration) error {
+	return s.PutCtx(context.Background(), key, data, duration)
+}
+
+func (s *SQLStore) PutCtx(ctx context.Context, key string, data any, duration time.Duration) error {
+	value, err := s.encode(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.db.ExecContext(ctx, s.queries.put, key, value, expiry(duration)); err != nil {
+		return fmt.Errorf("sql store: error saving item to the store: %w", err)
+	}
+
+	return nil
+}
+
+// PutIfAbsent inserts the item, replacing an existing row only if it has
+// expired, in a single statement. Whether the item was added is read from
+// the affected row count, which MySQL's clientFoundRows mode makes
+// ambiguous, so that mode is not supported.
+func (s *SQLStore) PutIfAbsent(ctx context.Context, key string, data any, duration time.Duration) (bool, error) {
+	value, err := s.encode(data)
+	if err != nil {
+		return false, err
+	}
+
+	current := now()
+	args := []any{key, value, expiry(duration), current}
+	if s.config.dialect == MySQL {
+		args = append(args, current)
+	}
+
+	result, err := s.db.ExecContext(ctx, s.queries.putIfAbsent, args...)
+	if err != nil {
+		return false, fmt.Errorf("sql store: error adding item to the store: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("sql store: error adding item to the store: %w", err)
+	}
+
+	// MySQL reports an updated row as two affected rows
+	return affected > 0, nil
+}
+
+// Increment adds by to the counter stored under key. Counters are kept as
+// decimal text rather than through the codec, so that the database can
+// add to them in a single upsert.
+func (s *SQLStore) Increment(ctx context.Context, key string, by int64, duration time.Duration) (int64, error) {
+	value, err := s.increment(ctx, s.queries.increment, key, strconv.AppendInt(nil, by, 10), by, duration)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("sql store: error reading counter `%s`: %w", key, err)
+	}
+
+	return n, nil
+}
+
+func (s *SQLStore) IncrementFloat(ctx context.Context, key string, by float64, duration time.Duration) (float64, error) {
+	value, err := s.increment(ctx, s.queries.incrementFloat, key, strconv.AppendFloat(nil, by, 'g', -1, 64), by, duration)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.ParseFloat(string(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("sql store: error reading counter `%s`: %w", key, err)
+	}
+
+	return n, nil
+}
+
+// increment runs an increment query, inserting initial if the counter is
+// missing or expired, and reads the new value back in the same
+// transaction.
+func (s *SQLStore) increment(ctx context.Context, query, key string, initial []byte, by any, duration time.Duration) ([]byte, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("sql store: error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current := now()
+	if _, err := tx.ExecContext(ctx, query, key, initial, expiry(duration), current, by, current); err != nil {
+		return nil, fmt.Errorf("sql store: error updating counter `%s`: %w", key, err)
+	}
+
+	var value []byte
+	if err := tx.QueryRowContext(ctx, s.queries.counter, key).Scan(&value); err != nil {
+		return nil, fmt.Errorf("sql store: error reading counter `%s`: %w", key, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("sql store: error updating counter `%s`: %w", key, err)
+	}
+
+	return value, nil
+}
+
+func (s *SQLStore) GetMany(ctx context.Context, keys []string) (map[string]any, error) {
+	items := make(map[string]any, len(keys))
+	if len(keys) == 0 {
+		return items, nil
+	}
+
+	args := make([]any, 0, len(keys)+1)
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	args = append(args, now())
+
+	rows, err := s.db.QueryContext(ctx, s.queries.getMany(len(keys)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("sql store: error getting cache data: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("sql store: error getting cache data: %w", err)
+		}
+
+		data, err := s.decode(value)
+		if err != nil {
+			return nil, err
+		}
+		items[key] = data
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sql store: error getting cache data: %w", err)
+	}
+
+	return items, nil
+}
+
+// PutMany saves every item in a single transaction.
+func (s *SQLStore) PutMany(ctx context.Context, items map[string]any, duration time.Duration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sql store: error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.queries.put)
+	if err != nil {
+		return fmt.Errorf("sql store: error saving items to the store: %w", err)
+	}
+	defer stmt.Close()
+
+	expires := expiry(duration)
+	for key, data := range items {
+		value, err := s.encode(data)
+		if err != nil {
+			return err
+		}
+
+		if _, err := stmt.ExecContext(ctx, key, value, expires); err != nil {
+			return fmt.Errorf("sql store: error saving items to the store: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sql store: error saving items to the store: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLStore) DeleteMany(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	args := make([]any, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+
+	if _, err := s.db.ExecContext(ctx, s.queries.deleteMany(len(keys)), args...); err != nil {
+		return fmt.Errorf("sql store: error deleting keys: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLStore) Delete(key string) error {
+	return s.DeleteCtx(context.Background(), key)
+}
+
+func (s *SQLStore) DeleteCtx(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, s.queries.delete, key); err != nil {
+		return fmt.Errorf("sql store: error deleting key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLStore) Flush() error {
+	return s.FlushCtx(context.Background())
+}
+
+func (s *SQLStore) FlushCtx(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, s.queries.flush); err != nil {
+		return fmt.Errorf("sql store: error flushing cache table: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLStore) FlushPrefix(ctx context.Context, prefix string) error {
+	if _, err := s.db.ExecContext(ctx, s.queries.flushPrefix, escapeLike(prefix)+"%"); err != nil {
+		return fmt.Errorf("sql store: error flushing prefix: %w", err)
+	}
+
+	return nil
+}
+
+// FlushExpired deletes every expired row from the cache table.
+func (s *SQLStore) FlushExpired() error {
+	if _, err := s.db.Exec(s.queries.prune, now()); err != nil {
+		return fmt.Errorf("sql store: error pruning expired rows: %w", err)
+	}
+
+	return nil
+}
+
+// prune periodically deletes expired rows until the store is closed.
+func (s *SQLStore) prune() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.FlushExpired()
+		}
+	}
+}
+
+func (s *SQLStore) encode(data any) ([]byte, error) {
+	data, err := store.Encode(s.codec, data)
+	if err != nil {
+		return nil, fmt.Errorf("sql store: %w", err)
+	}
+
+	return data.([]byte), nil
+}
+
+func (s *SQLStore) decode(value []byte) (any, error) {
+	data, err := store.Decode(s.codec, value)
+	if err != nil {
+		return nil, fmt.Errorf("sql store: %w", err)
+	}
+
+	return data, nil
+}
+
+// clientFoundRows reports whether a MySQL DSN enables clientFoundRows,
+// which counts rows matched by an update as affected even if they were
+// left unchanged.
+func clientFoundRows(dsn string) bool {
+	_, params, ok := strings.Cut(dsn[strings.LastIndex(dsn, "/")+1:], "?")
+	if !ok {
+		return false
+	}
+
+	values, err := url.ParseQuery(params)
+	if err != nil {
+		return false
+	}
+
+	enabled, _ := strconv.ParseBool(values.Get("clientFoundRows"))
+	return enabled
+}
+
+// now returns the current time in unix milliseconds.
+func now() int64 {
+	return time.Now().UnixMilli()
+}
+
+// expiry returns the expiry time for an entry stored for duration.
+func expiry(duration time.Duration) int64 {
+	if duration <= 0 {
+		return 0
+	}
+
+	return time.Now().Add(duration).UnixMilli()
+}
@@ -0,0 +1,300 @@
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLStore(t *testing.T) {
+	store := NewSQLStore().(*SQLStore)
+	require.NoError(t, WithDSN("sqlite", filepath.Join(t.TempDir(), "cache.db"))(store))
+	require.NoError(t, WithTable("app_cache")(store))
+	require.NoError(t, WithAutoMigrate()(store))
+	require.NoError(t, WithPruneInterval(0)(store))
+
+	err := store.Init()
+	require.NoError(t, err, "Failed to initialize sql store")
+	defer store.Close()
+
+	t.Run("Put and Get", func(t *testing.T) {
+		err := store.Put("key", "value", time.Minute)
+		assert.NoError(t, err)
+
+		val, err := store.Get("key")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", val)
+
+		err = store.Put("key", "updated", time.Minute)
+		assert.NoError(t, err)
+
+		val, err = store.Get("key")
+		assert.NoError(t, err)
+		assert.Equal(t, "updated", val, "Put should replace existing rows")
+	})
+
+	t.Run("Get - Key Does Not Exist", func(t *testing.T) {
+		val, err := store.Get("missing")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+
+		has, err := store.Has("missing")
+		assert.NoError(t, err)
+		assert.False(t, has)
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		err := store.Put("expiring", "value", 50*time.Millisecond)
+		assert.NoError(t, err)
+
+		time.Sleep(100 * time.Millisecond)
+
+		has, err := store.Has("expiring")
+		assert.NoError(t, err)
+		assert.False(t, has)
+
+		err = store.FlushExpired()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, store.count(t, "expiring"), "Expired row should be pruned")
+	})
+
+	t.Run("PutIfAbsent", func(t *testing.T) {
+		added, err := store.PutIfAbsent(context.Background(), "key", "other", time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, added)
+
+		val, _ := store.Get("key")
+		assert.Equal(t, "updated", val, "Existing row should be kept")
+
+		added, err = store.PutIfAbsent(context.Background(), "added", "value", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, added)
+	})
+
+	t.Run("PutIfAbsent - Expired Row", func(t *testing.T) {
+		err := store.Put("stale", "old", 10*time.Millisecond)
+		assert.NoError(t, err)
+
+		time.Sleep(50 * time.Millisecond)
+
+		added, err := store.PutIfAbsent(context.Background(), "stale", "new", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, added, "Expired rows should be replaced")
+
+		val, _ := store.Get("stale")
+		assert.Equal(t, "new", val)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		items := map[string]any{"batch1": "value1", "batch2": "value2"}
+
+		err := store.PutMany(context.Background(), items, time.Minute)
+		assert.NoError(t, err)
+
+		vals, err := store.GetMany(context.Background(), []string{"batch1", "batch2", "missing"})
+		assert.NoError(t, err)
+		assert.Equal(t, items, vals)
+
+		err = store.DeleteMany(context.Background(), []string{"batch1", "batch2"})
+		assert.NoError(t, err)
+
+		vals, err = store.GetMany(context.Background(), []string{"batch1", "batch2"})
+		assert.NoError(t, err)
+		assert.Empty(t, vals)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		err := store.Delete("key")
+		assert.NoError(t, err)
+
+		has, err := store.Has("key")
+		assert.NoError(t, err)
+		assert.False(t, has)
+
+		err = store.Delete("key")
+		assert.NoError(t, err, "Deleting a missing key should not fail")
+	})
+
+	t.Run("FlushPrefix", func(t *testing.T) {
+		_ = store.Put("svc_a:key1", "value1", time.Minute)
+		_ = store.Put("svcXa:key1", "value1", time.Minute)
+
+		err := store.FlushPrefix(context.Background(), "svc_a:")
+		assert.NoError(t, err)
+
+		has, _ := store.Has("svc_a:key1")
+		assert.False(t, has)
+
+		has, _ = store.Has("svcXa:key1")
+		assert.True(t, has, "LIKE wildcards in the prefix should be escaped")
+	})
+
+	t.Run("Flush", func(t *testing.T) {
+		_ = store.Put("key1", "value1", time.Minute)
+		_ = store.Put("key2", "value2", time.Minute)
+
+		err := store.Flush()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, store.count(t, ""))
+	})
+}
+
+func TestSQLStore_WithDB(t *testing.T) {
+	db, err := dbsql.Open("sqlite", filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, Migrate(context.Background(), db, SQLite, "cache"))
+	require.NoError(t, Migrate(context.Background(), db, SQLite, "cache"), "Migrate should be idempotent")
+
+	store := NewSQLStore()
+	require.NoError(t, WithDB(db, SQLite)(store))
+	require.NoError(t, store.Init())
+	require.NoError(t, store.Put("key", "value", -1))
+	require.NoError(t, store.(*SQLStore).Close())
+
+	assert.NoError(t, db.Ping(), "Store should not close a database it did not open")
+}
+
+func TestSQLStore_Options(t *testing.T) {
+	store := NewSQLStore()
+
+	assert.Error(t, WithTable("cache; DROP TABLE users")(store))
+	assert.Error(t, WithDialect("oracle")(store))
+	assert.Error(t, store.Init(), "A database is required")
+}
+
+func TestQueries(t *testing.T) {
+	postgres := newQueries(Postgres, "public.cache")
+	assert.Equal(t,
+		`INSERT INTO "public"."cache" ("key", "value", "expires_at") VALUES ($1, $2, $3) ON CONFLICT ("key") DO UPDATE SET "value" = excluded."value", "expires_at" = excluded."expires_at"`,
+		postgres.put)
+	assert.Equal(t, `DELETE FROM "public"."cache" WHERE "key" IN ($1, $2)`, postgres.deleteMany(2))
+
+	assert.Equal(t,
+		`INSERT INTO "public"."cache" ("key", "value", "expires_at") VALUES ($1, $2, $3) ON CONFLICT ("key") DO UPDATE SET "value" = CASE WHEN "public"."cache"."expires_at" <> 0 AND "public"."cache"."expires_at" <= $4 THEN excluded."value" ELSE convert_to((convert_from("public"."cache"."value", 'UTF8')::BIGINT + $5)::TEXT, 'UTF8') END, "expires_at" = CASE WHEN "public"."cache"."expires_at" <> 0 AND "public"."cache"."expires_at" <= $6 THEN excluded."expires_at" ELSE "public"."cache"."expires_at" END`,
+		postgres.increment)
+
+	mysql := newQueries(MySQL, "cache")
+	assert.Equal(t,
+		"INSERT INTO `cache` (`key`, `value`, `expires_at`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `value` = VALUES(`value`), `expires_at` = VALUES(`expires_at`)",
+		mysql.put)
+	assert.Equal(t,
+		"INSERT INTO `cache` (`key`, `value`, `expires_at`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `value` = IF(`expires_at` <> 0 AND `expires_at` <= ?, VALUES(`value`), CAST(CAST(`value` AS SIGNED) + ? AS CHAR)), `expires_at` = IF(`expires_at` <> 0 AND `expires_at` <= ?, VALUES(`expires_at`), `expires_at`)",
+		mysql.increment)
+}
+
+func TestSQLStore_Increment(t *testing.T) {
+	store := NewSQLStore().(*SQLStore)
+	require.NoError(t, WithDSN("sqlite", filepath.Join(t.TempDir(), "cache.db"))(store))
+	require.NoError(t, WithAutoMigrate()(store))
+	require.NoError(t, WithPruneInterval(0)(store))
+	require.NoError(t, store.Init())
+	defer store.Close()
+
+	ctx := context.Background()
+
+	val, err := store.Increment(ctx, "counter", 2, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), val)
+
+	expiresAt := func(key string) int64 {
+		var n int64
+		require.NoError(t, store.db.QueryRow(`SELECT "expires_at" FROM "cache" WHERE "key" = ?`, key).Scan(&n))
+		return n
+	}
+	before := expiresAt("counter")
+
+	val, err = store.Increment(ctx, "counter", -5, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-3), val)
+	assert.Equal(t, before, expiresAt("counter"), "Incrementing should keep the expiry")
+
+	floatVal, err := store.IncrementFloat(ctx, "float", 0.5, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, floatVal)
+
+	floatVal, err = store.IncrementFloat(ctx, "float", 0.25, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.75, floatVal)
+
+	_, err = store.Increment(ctx, "expiring", 5, 50*time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	val, err = store.Increment(ctx, "expiring", 1, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), val, "An expired counter should start again from zero")
+	assert.Greater(t, expiresAt("expiring"), now(), "A restarted counter should take the new duration")
+}
+
+func TestSQLStore_ClientFoundRows(t *testing.T) {
+	assert.True(t, clientFoundRows("user:pass@tcp(localhost:3306)/app?parseTime=true&clientFoundRows=true"))
+	assert.False(t, clientFoundRows("user:pass@tcp(localhost:3306)/app?clientFoundRows=false"))
+	assert.False(t, clientFoundRows("user:p?ss@tcp(localhost:3306)/app"))
+
+	store := NewSQLStore()
+	require.NoError(t, WithDSN("mysql", "user:pass@tcp(localhost:3306)/app?clientFoundRows=1")(store))
+	assert.ErrorContains(t, store.Init(), "clientFoundRows", "Add cannot tell inserts from no-ops in that mode")
+}
+
+// count returns the number of rows, live or expired, whose key starts with
+// prefix.
+func (s *SQLStore) count(t *testing.T, prefix string) int {
+	var n int
+	err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM "+s.config.dialect.quote(s.config.table)+` WHERE "key" LIKE ? ESCAPE '!'`,
+		escapeLike(prefix)+"%",
+	).Scan(&n)
+	require.NoError(t, err)
+	return n
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    Config
+		fields []string
+	}{
+		{"Missing database", Config{}, []string{"DB"}},
+		{"DSN without driver", Config{DB: &dbsql.DB{}, Dialect: SQLite, DSN: "cache.db"}, []string{"DriverName"}},
+		{"DB without dialect", Config{DB: &dbsql.DB{}}, []string{"Dialect"}},
+		{"Unknown driver", Config{DriverName: "oracle"}, []string{"Dialect"}},
+		{"Invalid table", Config{DriverName: "sqlite", Table: "cache; drop"}, []string{"Table"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg)
+			var fields []string
+			for _, fieldError := range store.FieldErrors(err) {
+				fields = append(fields, fieldError.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+
+	sqlStore, err := New(Config{
+		DriverName:    "sqlite",
+		DSN:           filepath.Join(t.TempDir(), "cache.db"),
+		AutoMigrate:   true,
+		PruneInterval: -1,
+	})
+	require.NoError(t, err)
+	require.NoError(t, sqlStore.Init())
+	defer sqlStore.Close()
+
+	assert.Equal(t, "cache", sqlStore.config.table, "Zero fields should take the defaults")
+
+	require.NoError(t, sqlStore.Put("key", "value", time.Minute))
+	val, err := sqlStore.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+}
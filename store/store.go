@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"time"
 )
 
@@ -24,6 +25,138 @@ type Store interface {
 
 	// Flush removes all values from the store.
 	Flush() error
+
+	// Close stops any background work and releases the resources held by
+	// the store. The store must not be used after it is closed.
+	Close() error
+}
+
+// ContextStore is a Store whose operations accept a context.Context,
+// allowing callers to propagate cancellation and deadlines.
+type ContextStore interface {
+	Store
+
+	// HasCtx checks if a value exists in the store for the given key.
+	HasCtx(ctx context.Context, key string) (bool, error)
+
+	// GetCtx retrieves the value associated with the given key.
+	// Returns nil if the key does not exist.
+	GetCtx(ctx context.Context, key string) (any, error)
+
+	// PutCtx stores the value under the specified key with a duration.
+	PutCtx(ctx context.Context, key string, data any, duration time.Duration) error
+
+	// DeleteCtx removes the value associated with the specified key.
+	DeleteCtx(ctx context.Context, key string) error
+
+	// FlushCtx removes all values from the store.
+	FlushCtx(ctx context.Context) error
+}
+
+// BatchStore is implemented by stores that can read and write several
+// keys in a single round-trip.
+type BatchStore interface {
+	// GetMany retrieves the values for the given keys. Keys that do not
+	// exist are left out of the result.
+	GetMany(ctx context.Context, keys []string) (map[string]any, error)
+
+	// PutMany stores every item under its key with the same duration.
+	PutMany(ctx context.Context, items map[string]any, duration time.Duration) error
+
+	// DeleteMany removes the values associated with the given keys.
+	DeleteMany(ctx context.Context, keys []string) error
+}
+
+// Adder is implemented by stores that can atomically store a value only
+// when its key is absent.
+type Adder interface {
+	// PutIfAbsent stores the value under the specified key unless the key
+	// already exists, and reports whether the value was stored.
+	PutIfAbsent(ctx context.Context, key string, data any, duration time.Duration) (bool, error)
+}
+
+// Counter is implemented by stores that can atomically adjust numeric values.
+// Counter values are stored as plain numbers rather than through the
+// store's Codec, so that the store can update them natively.
+type Counter interface {
+	// Increment adds by to the integer stored under key and returns the new
+	// value. Missing keys start at zero and are given the specified duration;
+	// the expiry of existing keys is left untouched.
+	Increment(ctx context.Context, key string, by int64, duration time.Duration) (int64, error)
+
+	// IncrementFloat is like Increment for floating point values.
+	IncrementFloat(ctx context.Context, key string, by float64, duration time.Duration) (float64, error)
+}
+
+// PrefixFlusher is implemented by stores that can remove every key
+// starting with a prefix, leaving other keys untouched.
+type PrefixFlusher interface {
+	FlushPrefix(ctx context.Context, prefix string) error
+}
+
+// Locker is implemented by stores that can hold a lock on a key across
+// processes while its value is being recomputed.
+type Locker interface {
+	// Lock blocks until the lock for key is acquired or ctx is done.
+	// The returned function releases the lock.
+	Lock(ctx context.Context, key string) (unlock func(), err error)
+}
+
+// HealthChecker is implemented by stores that can report whether their
+// backend is reachable and usable.
+type HealthChecker interface {
+	// Ping returns an error if the store cannot currently serve requests.
+	Ping(ctx context.Context) error
 }
 
 type Option func(store Store) error
+
+// WithContext returns s as a ContextStore. Stores that do not implement
+// ContextStore are wrapped so that each call checks the context before
+// delegating to the context-free method.
+func WithContext(s Store) ContextStore {
+	if cs, ok := s.(ContextStore); ok {
+		return cs
+	}
+	return contextStore{s}
+}
+
+// contextStore adapts a plain Store to the ContextStore interface.
+type contextStore struct {
+	Store
+}
+
+func (s contextStore) HasCtx(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return s.Has(key)
+}
+
+func (s contextStore) GetCtx(ctx context.Context, key string) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Get(key)
+}
+
+func (s contextStore) PutCtx(ctx context.Context, key string, data any, duration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Put(key, data, duration)
+}
+
+func (s contextStore) DeleteCtx(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Delete(key)
+}
+
+func (s contextStore) FlushCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Flush()
+}
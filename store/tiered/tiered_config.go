@@ -0,0 +1,57 @@
+package tiered
+
+import (
+	"errors"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+// Config configures a tiered store created with New.
+type Config struct {
+	// L1 is the store in front, usually an in-process store. Required.
+	L1 store.Store
+
+	// L2 is the shared store behind. Required.
+	L2 store.Store
+
+	// L1TTL caps how long entries are kept in L1. Defaults to a minute. It
+	// cannot be disabled, as it bounds how long L1 serves entries L2 has
+	// already expired.
+	L1TTL time.Duration
+}
+
+// New returns a tiered store configured by cfg, or the field errors of an
+// invalid cfg. Pass the store to cachey.NewFromStore, which initializes it
+// along with both tiers.
+func New(cfg Config) (*TieredStore, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	s := NewTieredStore(cfg.L1, cfg.L2).(*TieredStore)
+	if cfg.L1TTL != 0 {
+		s.config.l1TTL = cfg.L1TTL
+	}
+
+	return s, nil
+}
+
+func (cfg Config) validate() error {
+	var errs []error
+	invalid := func(field, reason string) {
+		errs = append(errs, &store.FieldError{Store: "tiered", Field: field, Reason: reason})
+	}
+
+	if cfg.L1 == nil {
+		invalid("L1", "is required")
+	}
+	if cfg.L2 == nil {
+		invalid("L2", "is required")
+	}
+	if cfg.L1TTL < 0 {
+		invalid("L1TTL", "must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,60 @@
+package tiered
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+// WithL1TTL caps how long entries are kept in L1. The TTL must be
+// positive: L1 copies filled on read cannot see how long their L2 entry
+// has left, so the TTL is what makes them notice it expired.
+func WithL1TTL(ttl time.Duration) store.Option {
+	return func(s store.Store) error {
+		tieredStore, ok := s.(*TieredStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for tiered options")
+		}
+
+		if ttl <= 0 {
+			return fmt.Errorf("tiered store: l1 TTL must be positive")
+		}
+
+		tieredStore.config.l1TTL = ttl
+		return nil
+	}
+}
+
+// WithL1Options applies options to the L1 store.
+func WithL1Options(options ...store.Option) store.Option {
+	return func(s store.Store) error {
+		tieredStore, ok := s.(*TieredStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for tiered options")
+		}
+
+		return apply(tieredStore.l1, options)
+	}
+}
+
+// WithL2Options applies options to the L2 store.
+func WithL2Options(options ...store.Option) store.Option {
+	return func(s store.Store) error {
+		tieredStore, ok := s.(*TieredStore)
+		if !ok {
+			return fmt.Errorf("invalid store type for tiered options")
+		}
+
+		return apply(tieredStore.l2, options)
+	}
+}
+
+func apply(s store.Store, options []store.Option) error {
+	for _, option := range options {
+		if err := option(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
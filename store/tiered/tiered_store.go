@@ -0,0 +1,366 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+type config struct {
+	l1TTL time.Duration
+}
+
+// TieredStore layers a fast, usually in-process, L1 store in front of a
+// shared L2 store. Reads are served from L1 when possible and fill it from
+// L2 otherwise; writes go to L2 first and then to L1, and deletes clear L2
+// before L1 so concurrent reads cannot refill L1 with deleted values.
+//
+// L1 entries live for at most the L1 TTL, which bounds how long a process
+// can serve a value that another process has changed in L2. When L2
+// implements store.Invalidator, such changes also evict the L1 copy right
+// away. Failures to fill L1 on read are ignored, as L2 remains
+// authoritative.
+type TieredStore struct {
+	config *config
+	l1, l2 store.Store // The stores as given, on which options and optional interfaces are looked up.
+
+	l1Ctx, l2Ctx store.ContextStore
+}
+
+func NewTieredStore(l1, l2 store.Store) store.Store {
+	defaultConfig := config{
+		l1TTL: time.Minute,
+	}
+
+	return &TieredStore{
+		config: &defaultConfig,
+		l1:     l1,
+		l2:     l2,
+		l1Ctx:  store.WithContext(l1),
+		l2Ctx:  store.WithContext(l2),
+	}
+}
+
+func (s *TieredStore) Init() error {
+	if s.config == nil {
+		return errors.New("tiered store: configuration is missing")
+	}
+
+	if s.config.l1TTL <= 0 {
+		return errors.New("tiered store: l1 TTL must be positive")
+	}
+
+	if err := s.l1.Init(); err != nil {
+		return fmt.Errorf("tiered store: error initializing l1 store: %w", err)
+	}
+
+	if err := s.l2.Init(); err != nil {
+		s.l1.Close()
+		return fmt.Errorf("tiered store: error initializing l2 store: %w", err)
+	}
+
+	// drop L1 copies of entries other processes change in L2
+	if invalidator, ok := s.l2.(store.Invalidator); ok {
+		invalidator.OnInvalidate(func(inv store.Invalidation) {
+			store.Evict(context.Background(), s.l1, inv)
+		})
+	}
+
+	return nil
+}
+
+// Close closes both stores.
+func (s *TieredStore) Close() error {
+	return errors.Join(s.l1.Close(), s.l2.Close())
+}
+
+// Ping checks both stores, if they implement store.HealthChecker.
+func (s *TieredStore) Ping(ctx context.Context) error {
+	for _, st := range []store.Store{s.l1, s.l2} {
+		if checker, ok := st.(store.HealthChecker); ok {
+			if err := checker.Ping(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// L1 returns the store in front.
+func (s *TieredStore) L1() store.Store {
+	return s.l1
+}
+
+// L2 returns the store behind.
+func (s *TieredStore) L2() store.Store {
+	return s.l2
+}
+
+func (s *TieredStore) Has(key string) (bool, error) {
+	return s.HasCtx(context.Background(), key)
+}
+
+func (s *TieredStore) HasCtx(ctx context.Context, key string) (bool, error) {
+	if found, err := s.l1Ctx.HasCtx(ctx, key); err == nil && found {
+		return true, nil
+	}
+
+	return s.l2Ctx.HasCtx(ctx, key)
+}
+
+func (s *TieredStore) Get(key string) (any, error) {
+	return s.GetCtx(context.Background(), key)
+}
+
+func (s *TieredStore) GetCtx(ctx context.Context, key string) (any, error) {
+	if data, err := s.l1Ctx.GetCtx(ctx, key); err == nil && data != nil {
+		return data, nil
+	}
+
+	data, err := s.l2Ctx.GetCtx(ctx, key)
+	if err != nil || data == nil {
+		return data, err
+	}
+
+	s.l1Ctx.PutCtx(ctx, key, data, s.l1Duration(0))
+	return data, nil
+}
+
+func (s *TieredStore) Put(key string, data any, duration time.Duration) error {
+	return s.PutCtx(context.Background(), key, data, duration)
+}
+
+func (s *TieredStore) PutCtx(ctx context.Context, key string, data any, duration time.Duration) error {
+	if err := s.l2Ctx.PutCtx(ctx, key, data, duration); err != nil {
+		return err
+	}
+
+	return s.l1Ctx.PutCtx(ctx, key, data, s.l1Duration(duration))
+}
+
+// PutIfAbsent adds the item to L2, atomically when L2 implements
+// store.Adder, and copies it to L1 if it was added.
+func (s *TieredStore) PutIfAbsent(ctx context.Context, key string, data any, duration time.Duration) (bool, error) {
+	var added bool
+	if adder, ok := s.l2.(store.Adder); ok {
+		var err error
+		added, err = adder.PutIfAbsent(ctx, key, data, duration)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		found, err := s.l2Ctx.HasCtx(ctx, key)
+		if err != nil || found {
+			return false, err
+		}
+
+		if err := s.l2Ctx.PutCtx(ctx, key, data, duration); err != nil {
+			return false, err
+		}
+		added = true
+	}
+
+	if !added {
+		return false, nil
+	}
+
+	return true, s.l1Ctx.PutCtx(ctx, key, data, s.l1Duration(duration))
+}
+
+func (s *TieredStore) Increment(ctx context.Context, key string, by int64, duration time.Duration) (int64, error) {
+	counter, err := s.counter()
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := counter.Increment(ctx, key, by, duration)
+	if err != nil {
+		return 0, err
+	}
+
+	// the next read fetches the new value from L2
+	return value, s.l1Ctx.DeleteCtx(ctx, key)
+}
+
+func (s *TieredStore) IncrementFloat(ctx context.Context, key string, by float64, duration time.Duration) (float64, error) {
+	counter, err := s.counter()
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := counter.IncrementFloat(ctx, key, by, duration)
+	if err != nil {
+		return 0, err
+	}
+
+	return value, s.l1Ctx.DeleteCtx(ctx, key)
+}
+
+// counter returns L2 as a store.Counter. Counters always live in L2, as
+// L1 copies are not shared.
+func (s *TieredStore) counter() (store.Counter, error) {
+	counter, ok := s.l2.(store.Counter)
+	if !ok {
+		return nil, fmt.Errorf("tiered store: l2 store does not support counters: %w", errors.ErrUnsupported)
+	}
+	return counter, nil
+}
+
+func (s *TieredStore) GetMany(ctx context.Context, keys []string) (map[string]any, error) {
+	items, err := getMany(ctx, s.l1, keys)
+	if err != nil {
+		items = make(map[string]any, len(keys))
+	}
+
+	missing := make([]string, 0, len(keys)-len(items))
+	for _, key := range keys {
+		if _, ok := items[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) == 0 {
+		return items, nil
+	}
+
+	found, err := getMany(ctx, s.l2, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, data := range found {
+		items[key] = data
+	}
+	putMany(ctx, s.l1, found, s.l1Duration(0))
+
+	return items, nil
+}
+
+func (s *TieredStore) PutMany(ctx context.Context, items map[string]any, duration time.Duration) error {
+	if err := putMany(ctx, s.l2, items, duration); err != nil {
+		return err
+	}
+
+	return putMany(ctx, s.l1, items, s.l1Duration(duration))
+}
+
+// DeleteMany removes the keys from L2 before L1, so a concurrent read
+// cannot refill L1 with a value that is about to be deleted.
+func (s *TieredStore) DeleteMany(ctx context.Context, keys []string) error {
+	if err := deleteMany(ctx, s.l2, keys); err != nil {
+		return err
+	}
+
+	return deleteMany(ctx, s.l1, keys)
+}
+
+func (s *TieredStore) Delete(key string) error {
+	return s.DeleteCtx(context.Background(), key)
+}
+
+func (s *TieredStore) DeleteCtx(ctx context.Context, key string) error {
+	if err := s.l2Ctx.DeleteCtx(ctx, key); err != nil {
+		return err
+	}
+
+	return s.l1Ctx.DeleteCtx(ctx, key)
+}
+
+func (s *TieredStore) Flush() error {
+	return s.FlushCtx(context.Background())
+}
+
+func (s *TieredStore) FlushCtx(ctx context.Context) error {
+	if err := s.l2Ctx.FlushCtx(ctx); err != nil {
+		return err
+	}
+
+	return s.l1Ctx.FlushCtx(ctx)
+}
+
+func (s *TieredStore) FlushPrefix(ctx context.Context, prefix string) error {
+	l1, ok1 := s.l1.(store.PrefixFlusher)
+	l2, ok2 := s.l2.(store.PrefixFlusher)
+	if !ok1 || !ok2 {
+		return fmt.Errorf("tiered store: both stores must support flushing by prefix: %w", errors.ErrUnsupported)
+	}
+
+	if err := l2.FlushPrefix(ctx, prefix); err != nil {
+		return err
+	}
+
+	return l1.FlushPrefix(ctx, prefix)
+}
+
+// Lock takes the lock from L2 when it implements store.Locker, so values
+// are recomputed once across processes. Otherwise it does nothing.
+func (s *TieredStore) Lock(ctx context.Context, key string) (func(), error) {
+	locker, ok := s.l2.(store.Locker)
+	if !ok {
+		return func() {}, nil
+	}
+
+	return locker.Lock(ctx, key)
+}
+
+// l1Duration returns how long an entry stored for duration is kept in L1:
+// no longer than the L1 TTL, and never longer than the entry itself.
+// Entries filled on read pass zero and get the full L1 TTL.
+func (s *TieredStore) l1Duration(duration time.Duration) time.Duration {
+	if duration > 0 && duration < s.config.l1TTL {
+		return duration
+	}
+
+	return s.config.l1TTL
+}
+
+func getMany(ctx context.Context, s store.Store, keys []string) (map[string]any, error) {
+	if batch, ok := s.(store.BatchStore); ok {
+		return batch.GetMany(ctx, keys)
+	}
+
+	cs := store.WithContext(s)
+	items := make(map[string]any, len(keys))
+	for _, key := range keys {
+		data, err := cs.GetCtx(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			items[key] = data
+		}
+	}
+	return items, nil
+}
+
+func putMany(ctx context.Context, s store.Store, items map[string]any, duration time.Duration) error {
+	if batch, ok := s.(store.BatchStore); ok {
+		return batch.PutMany(ctx, items, duration)
+	}
+
+	cs := store.WithContext(s)
+	for key, data := range items {
+		if err := cs.PutCtx(ctx, key, data, duration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteMany(ctx context.Context, s store.Store, keys []string) error {
+	if batch, ok := s.(store.BatchStore); ok {
+		return batch.DeleteMany(ctx, keys)
+	}
+
+	cs := store.WithContext(s)
+	for _, key := range keys {
+		if err := cs.DeleteCtx(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
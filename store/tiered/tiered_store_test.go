@@ -0,0 +1,347 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/codemaestro64/cachey/store"
+	"github.com/codemaestro64/cachey/store/memory"
+	"github.com/codemaestro64/cachey/store/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T, options ...store.Option) (*TieredStore, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+
+	s := NewTieredStore(memory.NewMemoryStore(), redis.NewRedisStore()).(*TieredStore)
+	options = append([]store.Option{
+		WithL2Options(
+			redis.WithAddress(mr.Addr()),
+			redis.WithReadTimeout(5*time.Second),
+			redis.WithWriteTimeout(5*time.Second),
+		),
+	}, options...)
+
+	for _, option := range options {
+		require.NoError(t, option(s))
+	}
+	require.NoError(t, s.Init())
+	t.Cleanup(func() { s.Close() })
+
+	return s, mr
+}
+
+func TestTieredStore(t *testing.T) {
+	s, mr := newTestStore(t)
+	ctx := context.Background()
+
+	t.Run("Put writes through", func(t *testing.T) {
+		err := s.Put("key", "value", time.Minute)
+		assert.NoError(t, err)
+
+		val, err := s.L1().Get("key")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", val)
+
+		val, err = s.L2().Get("key")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", val)
+	})
+
+	t.Run("Get fills L1", func(t *testing.T) {
+		require.NoError(t, s.L2().Put("remote", "value", time.Minute))
+
+		val, err := s.Get("remote")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", val)
+
+		// served from L1 even when L2 no longer has it
+		mr.Del("remote")
+		val, err = s.Get("remote")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", val)
+	})
+
+	t.Run("Get - Key Does Not Exist", func(t *testing.T) {
+		val, err := s.Get("missing")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+
+		has, err := s.Has("missing")
+		assert.NoError(t, err)
+		assert.False(t, has)
+	})
+
+	t.Run("PutIfAbsent", func(t *testing.T) {
+		added, err := s.PutIfAbsent(ctx, "key", "other", time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, added)
+
+		added, err = s.PutIfAbsent(ctx, "added", "value", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, added)
+
+		val, _ := s.L1().Get("added")
+		assert.Equal(t, "value", val)
+	})
+
+	t.Run("Increment", func(t *testing.T) {
+		value, err := s.Increment(ctx, "counter", 2, time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), value)
+
+		_, _ = s.Get("counter")
+		value, err = s.Increment(ctx, "counter", 3, time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), value)
+
+		val, err := s.Get("counter")
+		assert.NoError(t, err)
+		assert.Equal(t, "5", val, "L1 copy should be evicted on increment")
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		items := map[string]any{"batch1": "value1", "batch2": "value2"}
+		require.NoError(t, s.PutMany(ctx, items, time.Minute))
+		require.NoError(t, s.L1().Delete("batch2"))
+
+		vals, err := s.GetMany(ctx, []string{"batch1", "batch2", "missing"})
+		assert.NoError(t, err)
+		assert.Equal(t, items, vals)
+
+		val, _ := s.L1().Get("batch2")
+		assert.Equal(t, "value2", val, "GetMany should fill L1")
+
+		require.NoError(t, s.DeleteMany(ctx, []string{"batch1", "batch2"}))
+		vals, err = s.GetMany(ctx, []string{"batch1", "batch2"})
+		assert.NoError(t, err)
+		assert.Empty(t, vals)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		require.NoError(t, s.Delete("key"))
+
+		has, err := s.Has("key")
+		assert.NoError(t, err)
+		assert.False(t, has)
+
+		has, _ = s.L1().Has("key")
+		assert.False(t, has)
+	})
+
+	t.Run("FlushPrefix", func(t *testing.T) {
+		_ = s.Put("svc-a:key1", "value1", time.Minute)
+		_ = s.Put("svc-b:key1", "value1", time.Minute)
+
+		require.NoError(t, s.FlushPrefix(ctx, "svc-a:"))
+
+		has, _ := s.Has("svc-a:key1")
+		assert.False(t, has)
+
+		has, _ = s.Has("svc-b:key1")
+		assert.True(t, has)
+	})
+
+	t.Run("Flush", func(t *testing.T) {
+		_ = s.Put("key1", "value1", time.Minute)
+
+		require.NoError(t, s.Flush())
+
+		has, _ := s.L1().Has("key1")
+		assert.False(t, has)
+
+		has, _ = s.L2().Has("key1")
+		assert.False(t, has)
+	})
+}
+
+func TestTieredStore_L1TTL(t *testing.T) {
+	s, mr := newTestStore(t, WithL1TTL(50*time.Millisecond))
+
+	require.NoError(t, s.Put("key", "value", time.Minute))
+
+	// another instance changes the shared value
+	require.NoError(t, s.L2().Put("key", "changed", time.Minute))
+
+	val, _ := s.Get("key")
+	assert.Equal(t, "value", val, "L1 should serve its copy until the L1 TTL passes")
+
+	time.Sleep(100 * time.Millisecond)
+
+	val, _ = s.Get("key")
+	assert.Equal(t, "changed", val, "L1 should be refilled from L2 after the L1 TTL")
+	assert.True(t, mr.Exists("key"))
+}
+
+func TestL1Duration(t *testing.T) {
+	s := NewTieredStore(memory.NewMemoryStore(), memory.NewMemoryStore()).(*TieredStore)
+	s.config.l1TTL = time.Minute
+
+	assert.Equal(t, time.Minute, s.l1Duration(time.Hour))
+	assert.Equal(t, time.Second, s.l1Duration(time.Second))
+	assert.Equal(t, time.Minute, s.l1Duration(-1), "Forever entries should be capped")
+	assert.Equal(t, time.Minute, s.l1Duration(0), "Read fills should be capped")
+
+	assert.Error(t, WithL1TTL(0)(s), "The L1 TTL cannot be disabled")
+	assert.Error(t, WithL1TTL(-1)(s), "The L1 TTL cannot be disabled")
+}
+
+func TestTieredStore_Invalidation(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	newStore := func() *TieredStore {
+		s := NewTieredStore(memory.NewMemoryStore(), redis.NewRedisStore()).(*TieredStore)
+		require.NoError(t, WithL2Options(
+			redis.WithAddress(mr.Addr()),
+			redis.WithReadTimeout(5*time.Second),
+			redis.WithWriteTimeout(5*time.Second),
+			redis.WithInvalidationChannel("cachey:invalidate"),
+		)(s))
+		require.NoError(t, s.Init())
+		t.Cleanup(func() { s.Close() })
+		return s
+	}
+
+	a, b := newStore(), newStore()
+
+	require.NoError(t, a.Put("key", "first", time.Minute))
+
+	val, _ := b.Get("key")
+	assert.Equal(t, "first", val)
+
+	require.NoError(t, a.Put("key", "second", time.Minute))
+
+	assert.Eventually(t, func() bool {
+		val, _ := b.Get("key")
+		return val == "second"
+	}, time.Second, 10*time.Millisecond, "Other instances should drop their L1 copy")
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{})
+	var fields []string
+	for _, fieldError := range store.FieldErrors(err) {
+		fields = append(fields, fieldError.Field)
+	}
+	assert.Equal(t, []string{"L1", "L2"}, fields)
+
+	_, err = New(Config{L1: memory.NewMemoryStore(), L2: memory.NewMemoryStore(), L1TTL: -1})
+	fields = nil
+	for _, fieldError := range store.FieldErrors(err) {
+		fields = append(fields, fieldError.Field)
+	}
+	assert.Equal(t, []string{"L1TTL"}, fields, "The L1 TTL cannot be disabled")
+
+	tieredStore, err := New(Config{L1: memory.NewMemoryStore(), L2: memory.NewMemoryStore()})
+	require.NoError(t, err)
+	require.NoError(t, tieredStore.Init())
+	defer tieredStore.Close()
+
+	assert.Equal(t, time.Minute, tieredStore.config.l1TTL)
+}
+
+// recordingStore decorates a store and logs the calls that reach it.
+type recordingStore struct {
+	store.Store
+	name    string
+	log     *[]string
+	initErr error
+}
+
+func (s *recordingStore) Init() error {
+	if s.initErr != nil {
+		return s.initErr
+	}
+	return s.Store.Init()
+}
+
+func (s *recordingStore) Close() error {
+	*s.log = append(*s.log, s.name+" close")
+	return s.Store.Close()
+}
+
+func (s *recordingStore) Delete(key string) error {
+	*s.log = append(*s.log, s.name+" delete")
+	return s.Store.Delete(key)
+}
+
+func (s *recordingStore) Flush() error {
+	*s.log = append(*s.log, s.name+" flush")
+	return s.Store.Flush()
+}
+
+func TestTieredStore_DeleteOrder(t *testing.T) {
+	var log []string
+	l1 := &recordingStore{Store: memory.NewMemoryStore(), name: "l1", log: &log}
+	l2 := &recordingStore{Store: memory.NewMemoryStore(), name: "l2", log: &log}
+
+	s := NewTieredStore(l1, l2).(*TieredStore)
+	require.NoError(t, s.Init())
+
+	// L2 is cleared first, so reads cannot refill L1 with the old value
+	require.NoError(t, s.Delete("key"))
+	require.NoError(t, s.DeleteMany(context.Background(), []string{"key"}))
+	require.NoError(t, s.Flush())
+	assert.Equal(t, []string{
+		"l2 delete", "l1 delete",
+		"l2 delete", "l1 delete",
+		"l2 flush", "l1 flush",
+	}, log)
+}
+
+func TestTieredStore_InitClosesL1(t *testing.T) {
+	var log []string
+	l1 := &recordingStore{Store: memory.NewMemoryStore(), name: "l1", log: &log}
+	l2 := &recordingStore{Store: memory.NewMemoryStore(), name: "l2", log: &log, initErr: errors.New("unreachable")}
+
+	s := NewTieredStore(l1, l2).(*TieredStore)
+	assert.Error(t, s.Init())
+	assert.Equal(t, []string{"l1 close"}, log, "L1 should be closed when L2 fails to initialize")
+}
+
+// batchStore is a plain store, without the Ctx methods, that implements
+// store.BatchStore and counts the batch calls.
+type batchStore struct {
+	store.Store
+	inner   *memory.MemoryStore
+	batches int
+}
+
+func (s *batchStore) GetMany(ctx context.Context, keys []string) (map[string]any, error) {
+	s.batches++
+	return s.inner.GetMany(ctx, keys)
+}
+
+func (s *batchStore) PutMany(ctx context.Context, items map[string]any, duration time.Duration) error {
+	s.batches++
+	return s.inner.PutMany(ctx, items, duration)
+}
+
+func (s *batchStore) DeleteMany(ctx context.Context, keys []string) error {
+	s.batches++
+	return s.inner.DeleteMany(ctx, keys)
+}
+
+func TestTieredStore_PlainBatchStore(t *testing.T) {
+	inner := memory.NewMemoryStore().(*memory.MemoryStore)
+	l2 := &batchStore{Store: inner, inner: inner}
+
+	s := NewTieredStore(memory.NewMemoryStore(), l2).(*TieredStore)
+	require.NoError(t, s.Init())
+	defer s.Close()
+
+	ctx := context.Background()
+	require.NoError(t, s.PutMany(ctx, map[string]any{"a": 1, "b": 2}, time.Minute))
+	require.NoError(t, s.L1().Flush())
+
+	items, err := s.GetMany(ctx, []string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": 1, "b": 2}, items)
+
+	require.NoError(t, s.DeleteMany(ctx, []string{"a", "b"}))
+	assert.Equal(t, 3, l2.batches, "L2 batch methods should be used")
+}
@@ -0,0 +1,314 @@
+package cachey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Key prefixes used to store tag versions and tagged entries.
+const (
+	tagVersionPrefix = "cachey:tag:"
+	taggedKeyPrefix  = "cachey:tagged:"
+)
+
+// TaggedCache is a view of a Cache whose entries belong to a set of tags.
+// Flushing the tagged cache invalidates every entry written under those
+// tags without touching the rest of the cache.
+//
+// Each tag has a version stored in the cache, and tagged entries live
+// under a key namespace derived from the versions of all their tags.
+// Flushing a tag assigns it a new version, so entries written under the
+// old namespace can no longer be reached and are left to expire.
+type TaggedCache struct {
+	cache *Cache   // The cache holding tag versions and entries.
+	tags  []string // Tag names, in the order they were given.
+}
+
+// Tags returns a view of the cache scoped to the given tags. Entries must
+// be read with the same tags, in the same order, that they were written with.
+func (c *Cache) Tags(names ...string) *TaggedCache {
+	return &TaggedCache{cache: c, tags: names}
+}
+
+// Has checks if a value exists in the tagged cache for the given key.
+func (t *TaggedCache) Has(key string) (bool, error) {
+	return t.HasCtx(context.Background(), key)
+}
+
+// HasCtx is like Has but carries a context for cancellation and deadlines.
+func (t *TaggedCache) HasCtx(ctx context.Context, key string) (bool, error) {
+	c, err := t.namespace(ctx)
+	if err != nil {
+		return false, err
+	}
+	return c.HasCtx(ctx, key)
+}
+
+// Get retrieves the value associated with the given key from the tagged cache.
+// Returns nil if the key does not exist.
+func (t *TaggedCache) Get(key string) (any, error) {
+	return t.GetCtx(context.Background(), key)
+}
+
+// GetCtx is like Get but carries a context for cancellation and deadlines.
+func (t *TaggedCache) GetCtx(ctx context.Context, key string) (any, error) {
+	c, err := t.namespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetCtx(ctx, key)
+}
+
+// GetOrDefault retrieves the value associated with the given key.
+// If the key does not exist, it calls the provided defaultFunc to get a default value.
+func (t *TaggedCache) GetOrDefault(key string, defaultFunc func() (any, error)) (any, error) {
+	return t.GetOrDefaultCtx(context.Background(), key, defaultFunc)
+}
+
+// GetOrDefaultCtx is like GetOrDefault but carries a context for cancellation and deadlines.
+func (t *TaggedCache) GetOrDefaultCtx(ctx context.Context, key string, defaultFunc func() (any, error)) (any, error) {
+	c, err := t.namespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetOrDefaultCtx(ctx, key, defaultFunc)
+}
+
+// Remember retrieves the value for the specified key from the tagged cache.
+// If it does not exist, it calls rememberFunc to generate the value,
+// stores it with the specified duration, and returns it.
+func (t *TaggedCache) Remember(key string, duration time.Duration, rememberFunc func() (any, error)) (any, error) {
+	return t.RememberCtx(context.Background(), key, duration, rememberFunc)
+}
+
+// RememberCtx is like Remember but carries a context for cancellation and deadlines.
+func (t *TaggedCache) RememberCtx(ctx context.Context, key string, duration time.Duration, rememberFunc func() (any, error)) (any, error) {
+	c, err := t.namespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.RememberCtx(ctx, key, duration, rememberFunc)
+}
+
+// RememberForever is like Remember but stores the generated value indefinitely.
+func (t *TaggedCache) RememberForever(key string, rememberFunc func() (any, error)) (any, error) {
+	return t.RememberForeverCtx(context.Background(), key, rememberFunc)
+}
+
+// RememberForeverCtx is like RememberForever but carries a context for cancellation and deadlines.
+func (t *TaggedCache) RememberForeverCtx(ctx context.Context, key string, rememberFunc func() (any, error)) (any, error) {
+	return t.RememberCtx(ctx, key, ForeverDuration, rememberFunc)
+}
+
+// Pull retrieves the value for the specified key from the tagged cache and
+// removes it. Returns the value or nil if it doesn't exist.
+func (t *TaggedCache) Pull(key string) (any, error) {
+	return t.PullCtx(context.Background(), key)
+}
+
+// PullCtx is like Pull but carries a context for cancellation and deadlines.
+func (t *TaggedCache) PullCtx(ctx context.Context, key string) (any, error) {
+	c, err := t.namespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.PullCtx(ctx, key)
+}
+
+// Put stores the given data in the tagged cache under the specified key
+// with the provided duration.
+func (t *TaggedCache) Put(key string, data any, duration time.Duration) error {
+	return t.PutCtx(context.Background(), key, data, duration)
+}
+
+// PutCtx is like Put but carries a context for cancellation and deadlines.
+func (t *TaggedCache) PutCtx(ctx context.Context, key string, data any, duration time.Duration) error {
+	c, err := t.namespace(ctx)
+	if err != nil {
+		return err
+	}
+	return c.PutCtx(ctx, key, data, duration)
+}
+
+// Forever stores the given data in the tagged cache indefinitely.
+func (t *TaggedCache) Forever(key string, data any) {
+	t.ForeverCtx(context.Background(), key, data)
+}
+
+// ForeverCtx is like Forever but carries a context for cancellation and deadlines.
+func (t *TaggedCache) ForeverCtx(ctx context.Context, key string, data any) {
+	t.PutCtx(ctx, key, data, ForeverDuration)
+}
+
+// Add stores the given data in the tagged cache only if the key does not
+// already exist, and reports whether it was stored.
+func (t *TaggedCache) Add(key string, data any, duration time.Duration) (bool, error) {
+	return t.AddCtx(context.Background(), key, data, duration)
+}
+
+// AddCtx is like Add but carries a context for cancellation and deadlines.
+func (t *TaggedCache) AddCtx(ctx context.Context, key string, data any, duration time.Duration) (bool, error) {
+	c, err := t.namespace(ctx)
+	if err != nil {
+		return false, err
+	}
+	return c.AddCtx(ctx, key, data, duration)
+}
+
+// Increment atomically adds by to the integer stored under key in the
+// tagged cache and returns the new value. See Cache.Increment.
+func (t *TaggedCache) Increment(key string, by int64, duration time.Duration) (int64, error) {
+	return t.IncrementCtx(context.Background(), key, by, duration)
+}
+
+// IncrementCtx is like Increment but carries a context for cancellation and deadlines.
+func (t *TaggedCache) IncrementCtx(ctx context.Context, key string, by int64, duration time.Duration) (int64, error) {
+	c, err := t.namespace(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return c.IncrementCtx(ctx, key, by, duration)
+}
+
+// Decrement atomically subtracts by from the integer stored under key in
+// the tagged cache and returns the new value. See Cache.Increment.
+func (t *TaggedCache) Decrement(key string, by int64, duration time.Duration) (int64, error) {
+	return t.DecrementCtx(context.Background(), key, by, duration)
+}
+
+// DecrementCtx is like Decrement but carries a context for cancellation and deadlines.
+func (t *TaggedCache) DecrementCtx(ctx context.Context, key string, by int64, duration time.Duration) (int64, error) {
+	return t.IncrementCtx(ctx, key, -by, duration)
+}
+
+// IncrementFloat atomically adds by to the floating point value stored
+// under key in the tagged cache and returns the new value. See
+// Cache.IncrementFloat.
+func (t *TaggedCache) IncrementFloat(key string, by float64, duration time.Duration) (float64, error) {
+	return t.IncrementFloatCtx(context.Background(), key, by, duration)
+}
+
+// IncrementFloatCtx is like IncrementFloat but carries a context for cancellation and deadlines.
+func (t *TaggedCache) IncrementFloatCtx(ctx context.Context, key string, by float64, duration time.Duration) (float64, error) {
+	c, err := t.namespace(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return c.IncrementFloatCtx(ctx, key, by, duration)
+}
+
+// DecrementFloat atomically subtracts by from the floating point value
+// stored under key in the tagged cache and returns the new value. See
+// Cache.IncrementFloat.
+func (t *TaggedCache) DecrementFloat(key string, by float64, duration time.Duration) (float64, error) {
+	return t.DecrementFloatCtx(context.Background(), key, by, duration)
+}
+
+// DecrementFloatCtx is like DecrementFloat but carries a context for cancellation and deadlines.
+func (t *TaggedCache) DecrementFloatCtx(ctx context.Context, key string, by float64, duration time.Duration) (float64, error) {
+	return t.IncrementFloatCtx(ctx, key, -by, duration)
+}
+
+// Forget removes the value associated with the specified key from the tagged cache.
+func (t *TaggedCache) Forget(key string) error {
+	return t.ForgetCtx(context.Background(), key)
+}
+
+// ForgetCtx is like Forget but carries a context for cancellation and deadlines.
+func (t *TaggedCache) ForgetCtx(ctx context.Context, key string) error {
+	c, err := t.namespace(ctx)
+	if err != nil {
+		return err
+	}
+	return c.ForgetCtx(ctx, key)
+}
+
+// Flush invalidates every entry written under any of the tags.
+func (t *TaggedCache) Flush() error {
+	return t.FlushCtx(context.Background())
+}
+
+// FlushCtx is like Flush but carries a context for cancellation and deadlines.
+func (t *TaggedCache) FlushCtx(ctx context.Context) error {
+	for _, tag := range t.tags {
+		version, err := newTagVersion()
+		if err != nil {
+			return err
+		}
+
+		if err := t.cache.PutCtx(ctx, tagVersionPrefix+tag, version, ForeverDuration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// namespace returns a view of the cache whose keys are scoped to the
+// current versions of the tags, creating versions for new tags.
+func (t *TaggedCache) namespace(ctx context.Context) (*Cache, error) {
+	versionKeys := make([]string, len(t.tags))
+	for i, tag := range t.tags {
+		versionKeys[i] = tagVersionPrefix + tag
+	}
+
+	versions, err := t.cache.GetManyCtx(ctx, versionKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha1.New()
+	for i, tag := range t.tags {
+		version, ok := versions[versionKeys[i]]
+		if !ok {
+			version, err = t.createVersion(ctx, versionKeys[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		fmt.Fprintf(hash, "%s=%s|", tag, versionString(version))
+	}
+
+	return t.cache.WithPrefix(taggedKeyPrefix + hex.EncodeToString(hash.Sum(nil)) + ":"), nil
+}
+
+// createVersion stores a version for a tag that has none yet. If another
+// caller creates one first, that version is returned instead.
+func (t *TaggedCache) createVersion(ctx context.Context, versionKey string) (any, error) {
+	version, err := newTagVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := t.cache.AddCtx(ctx, versionKey, version, ForeverDuration)
+	if err != nil || added {
+		return version, err
+	}
+
+	return t.cache.GetCtx(ctx, versionKey)
+}
+
+// newTagVersion returns a random tag version.
+func newTagVersion() (string, error) {
+	version := make([]byte, 8)
+	if _, err := rand.Read(version); err != nil {
+		return "", fmt.Errorf("error generating tag version: %w", err)
+	}
+	return hex.EncodeToString(version), nil
+}
+
+// versionString normalizes a tag version read back from a store.
+func versionString(version any) string {
+	switch v := version.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
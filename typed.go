@@ -0,0 +1,241 @@
+package cachey
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/codemaestro64/cachey/store"
+)
+
+// Typed is a type-safe facade over a Cache that stores and returns values of type T.
+type Typed[T any] struct {
+	cache *Cache      // The cache holding the values.
+	codec store.Codec // Codec used to encode values; nil stores values as-is.
+
+	storeCodec bool // Whether codec is the store's own, which encodes values itself.
+}
+
+// NewTyped returns a Typed facade over c. When codec is non-nil, values are
+// encoded to bytes before they are stored and decoded on the way out, which
+// lets structs round-trip through stores such as redis. When codec is nil,
+// values are stored as-is and must come back as a T.
+//
+// If the cache's store has a codec of its own, the store's codec takes
+// precedence: values are handed to the store as-is and the generic values it
+// reads back are converted into a T with the store's codec.
+func NewTyped[T any](c *Cache, codec store.Codec) *Typed[T] {
+	if codecStore, ok := c.Store().(store.CodecStore); ok && codecStore.Codec() != nil {
+		return &Typed[T]{cache: c, codec: codecStore.Codec(), storeCodec: true}
+	}
+	return &Typed[T]{cache: c, codec: codec}
+}
+
+// Has checks if a value exists in the cache for the given key.
+func (t *Typed[T]) Has(key string) (bool, error) {
+	return t.HasCtx(context.Background(), key)
+}
+
+// HasCtx is like Has but carries a context for cancellation and deadlines.
+func (t *Typed[T]) HasCtx(ctx context.Context, key string) (bool, error) {
+	return t.cache.HasCtx(ctx, key)
+}
+
+// Get retrieves the value associated with the given key. The boolean
+// result reports whether the key was found.
+func (t *Typed[T]) Get(key string) (T, bool, error) {
+	return t.GetCtx(context.Background(), key)
+}
+
+// GetCtx is like Get but carries a context for cancellation and deadlines.
+func (t *Typed[T]) GetCtx(ctx context.Context, key string) (T, bool, error) {
+	var value T
+
+	data, err := t.cache.GetCtx(ctx, key)
+	if err != nil || data == nil {
+		return value, false, err
+	}
+
+	value, err = t.decode(key, data)
+	if err != nil {
+		return value, false, err
+	}
+	return value, true, nil
+}
+
+// GetOrDefault retrieves the value associated with the given key.
+// If the key does not exist, it returns the result of defaultFunc.
+func (t *Typed[T]) GetOrDefault(key string, defaultFunc func() (T, error)) (T, error) {
+	return t.GetOrDefaultCtx(context.Background(), key, defaultFunc)
+}
+
+// GetOrDefaultCtx is like GetOrDefault but carries a context for cancellation and deadlines.
+func (t *Typed[T]) GetOrDefaultCtx(ctx context.Context, key string, defaultFunc func() (T, error)) (T, error) {
+	value, found, err := t.GetCtx(ctx, key)
+	if err != nil || found {
+		return value, err
+	}
+	return defaultFunc()
+}
+
+// Remember retrieves the value for the specified key from the cache.
+// If it does not exist, it calls rememberFunc to generate the value,
+// stores it in the cache with the specified duration, and returns it.
+// Errors returned by rememberFunc are passed to the caller and nothing is stored.
+func (t *Typed[T]) Remember(key string, duration time.Duration, rememberFunc func() (T, error)) (T, error) {
+	return t.RememberCtx(context.Background(), key, duration, rememberFunc)
+}
+
+// RememberCtx is like Remember but carries a context for cancellation and deadlines.
+func (t *Typed[T]) RememberCtx(ctx context.Context, key string, duration time.Duration, rememberFunc func() (T, error)) (T, error) {
+	data, err := t.cache.RememberCtx(ctx, key, duration, func() (any, error) {
+		value, err := rememberFunc()
+		if err != nil {
+			return nil, err
+		}
+		return t.encode(key, value)
+	})
+	if err != nil {
+		var value T
+		return value, err
+	}
+
+	return t.decode(key, data)
+}
+
+// RememberForever is like Remember but stores the generated value indefinitely.
+func (t *Typed[T]) RememberForever(key string, rememberFunc func() (T, error)) (T, error) {
+	return t.RememberForeverCtx(context.Background(), key, rememberFunc)
+}
+
+// RememberForeverCtx is like RememberForever but carries a context for cancellation and deadlines.
+func (t *Typed[T]) RememberForeverCtx(ctx context.Context, key string, rememberFunc func() (T, error)) (T, error) {
+	return t.RememberCtx(ctx, key, ForeverDuration, rememberFunc)
+}
+
+// Pull retrieves the value for the specified key and removes it from the cache.
+func (t *Typed[T]) Pull(key string) (T, bool, error) {
+	return t.PullCtx(context.Background(), key)
+}
+
+// PullCtx is like Pull but carries a context for cancellation and deadlines.
+func (t *Typed[T]) PullCtx(ctx context.Context, key string) (T, bool, error) {
+	value, found, err := t.GetCtx(ctx, key)
+	if err != nil || !found {
+		return value, found, err
+	}
+
+	if err := t.ForgetCtx(ctx, key); err != nil {
+		return value, found, err
+	}
+	return value, true, nil
+}
+
+// Put stores the given value in the cache under the specified key
+// with the provided duration.
+func (t *Typed[T]) Put(key string, value T, duration time.Duration) error {
+	return t.PutCtx(context.Background(), key, value, duration)
+}
+
+// PutCtx is like Put but carries a context for cancellation and deadlines.
+func (t *Typed[T]) PutCtx(ctx context.Context, key string, value T, duration time.Duration) error {
+	data, err := t.encode(key, value)
+	if err != nil {
+		return err
+	}
+	return t.cache.PutCtx(ctx, key, data, duration)
+}
+
+// Forever stores the given value in the cache indefinitely.
+func (t *Typed[T]) Forever(key string, value T) error {
+	return t.PutCtx(context.Background(), key, value, ForeverDuration)
+}
+
+// Add stores the given value only if the key does not already exist,
+// and reports whether it was stored.
+func (t *Typed[T]) Add(key string, value T, duration time.Duration) (bool, error) {
+	return t.AddCtx(context.Background(), key, value, duration)
+}
+
+// AddCtx is like Add but carries a context for cancellation and deadlines.
+func (t *Typed[T]) AddCtx(ctx context.Context, key string, value T, duration time.Duration) (bool, error) {
+	data, err := t.encode(key, value)
+	if err != nil {
+		return false, err
+	}
+	return t.cache.AddCtx(ctx, key, data, duration)
+}
+
+// Forget removes the value associated with the specified key from the cache.
+func (t *Typed[T]) Forget(key string) error {
+	return t.ForgetCtx(context.Background(), key)
+}
+
+// ForgetCtx is like Forget but carries a context for cancellation and deadlines.
+func (t *Typed[T]) ForgetCtx(ctx context.Context, key string) error {
+	return t.cache.ForgetCtx(ctx, key)
+}
+
+// encode converts value into the representation handed to the store.
+func (t *Typed[T]) encode(key string, value T) (any, error) {
+	if t.codec == nil || t.storeCodec {
+		return value, nil
+	}
+
+	data, err := t.codec.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding value for key `%s`: %w", key, err)
+	}
+	return data, nil
+}
+
+// decode converts data read from the store back into a T.
+func (t *Typed[T]) decode(key string, data any) (T, error) {
+	var value T
+
+	if v, ok := data.(T); ok && (t.codec == nil || t.storeCodec) {
+		return v, nil
+	}
+
+	if t.storeCodec {
+		return t.convert(key, data)
+	}
+
+	var raw []byte
+	switch d := data.(type) {
+	case []byte:
+		raw = d
+	case string:
+		raw = []byte(d)
+	default:
+		if v, ok := data.(T); ok {
+			return v, nil
+		}
+		return value, fmt.Errorf("cached value for key `%s` is %T, not %T", key, data, value)
+	}
+
+	if t.codec == nil {
+		return value, fmt.Errorf("cached value for key `%s` is %T, not %T", key, data, value)
+	}
+
+	if err := t.codec.Unmarshal(raw, &value); err != nil {
+		return value, fmt.Errorf("error decoding value for key `%s`: %w", key, err)
+	}
+	return value, nil
+}
+
+// convert turns a generic value decoded by the store's codec, such as the
+// map[string]any JSON yields for a struct, into a T by round-tripping it
+// through the same codec.
+func (t *Typed[T]) convert(key string, data any) (T, error) {
+	var value T
+
+	raw, err := t.codec.Marshal(data)
+	if err == nil {
+		err = t.codec.Unmarshal(raw, &value)
+	}
+	if err != nil {
+		return value, fmt.Errorf("error decoding value for key `%s`: %w", key, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,151 @@
+package cachey
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/codemaestro64/cachey/store"
+	"github.com/codemaestro64/cachey/store/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type typedUser struct {
+	ID   int
+	Name string
+}
+
+func testTypedCache(t *testing.T, cache *Cache, codec store.Codec) {
+	users := NewTyped[typedUser](cache, codec)
+	user := typedUser{ID: 42, Name: "Ada"}
+
+	// 1. missing keys report not found
+	_, found, err := users.Get("user:42")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	// 2. stored values round-trip as T
+	err = users.Put("user:42", user, time.Minute)
+	assert.NoError(t, err)
+
+	cachedUser, found, err := users.Get("user:42")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, user, cachedUser)
+
+	// 3. Remember does not call the loader on a hit
+	rememberedUser, err := users.Remember("user:42", time.Minute, func() (typedUser, error) {
+		t.Fatal("loader should not be called on a cache hit")
+		return typedUser{}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, user, rememberedUser)
+
+	// 4. loader errors are returned and nothing is cached
+	loadErr := errors.New("database unavailable")
+	_, err = users.Remember("user:7", time.Minute, func() (typedUser, error) {
+		return typedUser{}, loadErr
+	})
+	assert.ErrorIs(t, err, loadErr)
+
+	has, err := users.Has("user:7")
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	// 5. Pull returns the value and removes it
+	pulledUser, found, err := users.Pull("user:42")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, user, pulledUser)
+
+	has, err = users.Has("user:42")
+	assert.NoError(t, err)
+	assert.False(t, has)
+}
+
+func TestTypedMemoryCache(t *testing.T) {
+	t.Run("Without Codec", func(t *testing.T) {
+		memoryCache, _ := New(MemoryStore)
+		testTypedCache(t, memoryCache, nil)
+	})
+
+	t.Run("JSON Codec", func(t *testing.T) {
+		memoryCache, _ := New(MemoryStore)
+		testTypedCache(t, memoryCache, store.JSONCodec{})
+	})
+
+	t.Run("Msgpack Codec", func(t *testing.T) {
+		memoryCache, _ := New(MemoryStore)
+		testTypedCache(t, memoryCache, store.MsgpackCodec{})
+	})
+}
+
+func TestTypedRedisCache(t *testing.T) {
+	mr, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer mr.Close()
+
+	redisCache, err := New(RedisStore,
+		redis.WithAddress(mr.Addr()),
+		redis.WithReadTimeout(5*time.Second),
+		redis.WithWriteTimeout(5*time.Second),
+	)
+	require.NoError(t, err)
+
+	testTypedCache(t, redisCache, store.JSONCodec{})
+}
+
+func TestTypedCodecStore(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	codecs := map[string]store.Codec{
+		"JSON":    store.JSONCodec{},
+		"Msgpack": store.MsgpackCodec{},
+	}
+	for name, codec := range codecs {
+		t.Run(name+" Store Without Codec", func(t *testing.T) {
+			memoryCache, err := New(MemoryStore, store.WithCodec(codec))
+			require.NoError(t, err)
+			testTypedCache(t, memoryCache, nil)
+		})
+
+		t.Run(name+" Store With JSON Codec", func(t *testing.T) {
+			memoryCache, err := New(MemoryStore, store.WithCodec(codec))
+			require.NoError(t, err)
+			testTypedCache(t, memoryCache, store.JSONCodec{})
+		})
+	}
+
+	t.Run("JSON Redis Store With JSON Codec", func(t *testing.T) {
+		mr.FlushAll()
+		redisCache, err := New(RedisStore,
+			redis.WithAddress(mr.Addr()),
+			store.WithCodec(store.JSONCodec{}),
+		)
+		require.NoError(t, err)
+		testTypedCache(t, redisCache, store.JSONCodec{})
+	})
+
+	t.Run("JSON Redis Store Without Codec", func(t *testing.T) {
+		mr.FlushAll()
+		redisCache, err := New(RedisStore,
+			redis.WithAddress(mr.Addr()),
+			store.WithCodec(store.JSONCodec{}),
+		)
+		require.NoError(t, err)
+		testTypedCache(t, redisCache, nil)
+	})
+}
+
+func TestTypedTypeMismatch(t *testing.T) {
+	memoryCache, _ := New(MemoryStore)
+	err := memoryCache.Put("key", "not a number", ForeverDuration)
+	assert.NoError(t, err)
+
+	_, _, err = NewTyped[int](memoryCache, nil).Get("key")
+	assert.Error(t, err)
+}